@@ -0,0 +1,239 @@
+package sparsetable
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The AT&T tabular text format is used by OpenFst and HFST to exchange
+// finite state machines. Each line is either an arc
+//
+//	src	dst	in	out	[weight]
+//
+// or a final state
+//
+//	state	[weight]
+//
+// with tab separated fields. The source state of the first line is the
+// initial state. Since a DFA is an acceptor, the input and output
+// symbols of all arcs are equal. Symbols are UTF-8 runes; spaces and tabs
+// are written as @_SPACE_@ and @_TAB_@ (as HFST does). The data of final
+// states is written as their final weight.
+
+const (
+	attSpace    = "@_SPACE_@"
+	attTab      = "@_TAB_@"
+	attEpsilon  = "@0@"
+	attEpsilon2 = "@_EPSILON_SYMBOL_@"
+)
+
+// WriteATT writes the DFA in the AT&T text format to the given writer.
+// The states are numbered densely in breadth-first order starting with 0
+// for the initial state. The arcs are written without weights. WriteATT
+// returns an error if the DFA contains malformed UTF-8 or newlines.
+func (d *DFA) WriteATT(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	if d.valid(d.initial, validAnyState) {
+		if err := d.writeATT(out); err != nil {
+			return errors.Wrapf(err, "could not write att")
+		}
+	}
+	return errors.Wrapf(out.Flush(), "could not write att")
+}
+
+func (d *DFA) writeATT(out io.Writer) error {
+	ids := map[State]int{d.initial: 0}
+	queue := []State{d.initial}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		var symerr error
+		err := d.eachUTF8Transition(s, UTF8Error, func(r rune, _ []byte, t State) {
+			sym, err := attSymbol(r)
+			if err != nil {
+				symerr = err
+				return
+			}
+			id, ok := ids[t]
+			if !ok {
+				id = len(ids)
+				ids[t] = id
+				queue = append(queue, t)
+			}
+			fmt.Fprintf(out, "%d\t%d\t%s\t%s\n", ids[s], id, sym, sym)
+		})
+		if err != nil {
+			return err
+		}
+		if symerr != nil {
+			return symerr
+		}
+		if data, final := d.Final(s); final {
+			fmt.Fprintf(out, "%d\t%d\n", ids[s], data)
+		}
+	}
+	return nil
+}
+
+func attSymbol(r rune) (string, error) {
+	switch r {
+	case ' ':
+		return attSpace, nil
+	case '\t':
+		return attTab, nil
+	case '\n':
+		return "", errors.New("cannot write newline symbol")
+	}
+	return string(r), nil
+}
+
+type attArc struct {
+	dst    int
+	sym    string
+	weight float64
+}
+
+type attEntry struct {
+	word   string
+	weight float64
+}
+
+// ReadATT reads a DFA in the AT&T text format from the given reader.
+// The weights of the arcs along the path of a word and the final weight
+// are summed up to the data of the word; the sum must be an integer in
+// the range of int32. Missing weights are 0. ReadATT returns an error if
+// the machine is not an acceptor, contains epsilons or is cyclic.
+// The options are passed to the Builder that builds the DFA, so words
+// that are accepted by more than one path are handled by its duplicate
+// policy.
+func ReadATT(r io.Reader, opts ...BuilderOption) (*DFA, error) {
+	arcs := make(map[int][]attArc)
+	finals := make(map[int]float64)
+	initial := -1
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxLineLength)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		src, err := parseATTLine(line, arcs, finals)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read att: line %d", n)
+		}
+		if initial < 0 {
+			initial = src
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrapf(err, "could not read att")
+	}
+	var entries []attEntry
+	if initial >= 0 {
+		var err error
+		entries, err = attEntries(initial, arcs, finals)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read att")
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].word < entries[j].word
+	})
+	b := NewBuilder(opts...)
+	for _, e := range entries {
+		if e.weight != math.Trunc(e.weight) || e.weight < math.MinInt32 || e.weight > math.MaxInt32 {
+			return nil, errors.Errorf("could not read att: invalid weight %g of %q", e.weight, e.word)
+		}
+		if err := b.Add(e.word, int32(e.weight)); err != nil {
+			return nil, errors.Wrapf(err, "could not read att")
+		}
+	}
+	return b.Build(), nil
+}
+
+// parseATTLine parses an arc or final state line
+// and returns its (source) state.
+func parseATTLine(line string, arcs map[int][]attArc, finals map[int]float64) (int, error) {
+	fields := strings.Split(line, "\t")
+	var weight float64
+	switch len(fields) {
+	case 2, 5:
+		w, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid weight in %q", line)
+		}
+		weight = w
+	case 1, 4:
+	default:
+		return 0, errors.Errorf("invalid line %q", line)
+	}
+	src, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid state in %q", line)
+	}
+	if len(fields) < 4 {
+		finals[src] = weight
+		return src, nil
+	}
+	dst, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid state in %q", line)
+	}
+	if fields[2] != fields[3] {
+		return 0, errors.Errorf("not an acceptor: %q", line)
+	}
+	sym, err := parseATTSymbol(fields[2])
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid symbol in %q", line)
+	}
+	arcs[src] = append(arcs[src], attArc{dst: dst, sym: sym, weight: weight})
+	return src, nil
+}
+
+func parseATTSymbol(sym string) (string, error) {
+	switch sym {
+	case attSpace:
+		return " ", nil
+	case attTab:
+		return "\t", nil
+	case attEpsilon, attEpsilon2:
+		return "", errors.New("epsilon")
+	case "":
+		return "", errors.New("empty symbol")
+	}
+	return sym, nil
+}
+
+// attEntries enumerates all paths from the initial state to final states.
+func attEntries(initial int, arcs map[int][]attArc, finals map[int]float64) ([]attEntry, error) {
+	var entries []attEntry
+	active := make(map[int]bool)
+	var path []byte
+	var walk func(int, float64) error
+	walk = func(s int, weight float64) error {
+		if active[s] {
+			return errors.Errorf("cycle at state %d", s)
+		}
+		if w, final := finals[s]; final {
+			entries = append(entries, attEntry{word: string(path), weight: weight + w})
+		}
+		active[s] = true
+		for _, arc := range arcs[s] {
+			path = append(path, arc.sym...)
+			if err := walk(arc.dst, weight+arc.weight); err != nil {
+				return err
+			}
+			path = path[:len(path)-len(arc.sym)]
+		}
+		active[s] = false
+		return nil
+	}
+	return entries, walk(initial, 0)
+}
@@ -0,0 +1,116 @@
+package sparsetable
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestATTRoundTrip(t *testing.T) {
+	tests := [][]string{
+		{},
+		{""},
+		{"abc", "def", "ghi"},
+		{"a b", "a\tb", "für", "yбĸ", "z│ή"},
+		teststrs,
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%v", tc), func(t *testing.T) {
+			dict := NewDictionary(tc...)
+			buffer := new(bytes.Buffer)
+			if err := dict.WriteATT(buffer); err != nil {
+				t.Fatalf("could not write att: %v", err)
+			}
+			dfa, err := ReadATT(buffer)
+			if err != nil {
+				t.Fatalf("could not read att: %v", err)
+			}
+			got, want := walkDFA(dfa), walkDFA(dict)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("expected %v; got %v", want, got)
+			}
+		})
+	}
+}
+
+func walkDFA(dfa *DFA) map[string]int32 {
+	res := make(map[string]int32)
+	dfa.Walk(func(str string, data int32) bool {
+		res[str] = data
+		return true
+	})
+	return res
+}
+
+func TestWriteATT(t *testing.T) {
+	b := NewBuilder()
+	for _, str := range []string{"a b", "ä"} {
+		if err := b.Add(str, 7); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+	buffer := new(bytes.Buffer)
+	if err := b.Build().WriteATT(buffer); err != nil {
+		t.Fatalf("could not write att: %v", err)
+	}
+	want := "0\t1\ta\ta\n0\t2\tä\tä\n1\t3\t@_SPACE_@\t@_SPACE_@\n2\t7\n3\t2\tb\tb\n"
+	if got := buffer.String(); got != want {
+		t.Fatalf("expected %q; got %q", want, got)
+	}
+	if err := NewDictionary("a\nb").WriteATT(new(bytes.Buffer)); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if err := NewDictionary("\xfc").WriteATT(new(bytes.Buffer)); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestReadATT(t *testing.T) {
+	tests := []struct {
+		name, att string
+		want      map[string]int32
+		err       bool
+	}{
+		{"weights", "0\t1\ta\ta\t1.5\n1\t2\tb\tb\t0.5\n2\t-3\n0\t5\n",
+			map[string]int32{"": 5, "ab": -1}, false},
+		{"multichar symbols", "0\t1\t+N\t+N\n1\t2\t@_TAB_@\t@_TAB_@\n2\n",
+			map[string]int32{"+N\t": 0}, false},
+		{"initial state", "3\t1\ta\ta\n1\n0\t1\tb\tb\n",
+			map[string]int32{"a": 0}, false},
+		{"empty", "", map[string]int32{}, false},
+		{"transducer", "0\t1\ta\tb\n1\n", nil, true},
+		{"epsilon", "0\t1\t@0@\t@0@\n1\n", nil, true},
+		{"cycle", "0\t1\ta\ta\n1\t0\tb\tb\n1\n", nil, true},
+		{"fractional weight", "0\t1\ta\ta\t0.5\n1\n", nil, true},
+		{"invalid line", "0\t1\ta\n", nil, true},
+		{"invalid state", "x\t1\ta\ta\n", nil, true},
+		{"duplicate path", "0\t1\ta\ta\n0\t2\ta\ta\n1\n2\n", nil, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dfa, err := ReadATT(strings.NewReader(tc.att))
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			got := walkDFA(dfa)
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Fatalf("expected %v; got %v", tc.want, got)
+			}
+		})
+	}
+	dfa, err := ReadATT(strings.NewReader("0\t1\ta\ta\n0\t2\ta\ta\t2\n1\t1\n2\n"),
+		WithDuplicatePolicy(DuplicateMax))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if data, ok := dfa.Lookup("a"); !ok || data != 2 {
+		t.Fatalf("expected 2, true; got %d, %t", data, ok)
+	}
+}
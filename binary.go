@@ -0,0 +1,394 @@
+package sparsetable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// The binary format of a DFA consists of a header, the raw cells of the
+// sparse table and a checksum. All numbers are little-endian.
+//
+//	offset  size  content
+//	0       4     magic "SPTB"
+//	4       4     format version (uint32)
+//	8       8     initial state (int64)
+//	16      8     number of cells n (uint64)
+//	24      8*n   cells: data (int32), char, next, type, 0
+//	24+8*n  4     CRC-32 (IEEE) of all preceding bytes
+//
+// The layout of the cells matches the in-memory layout of Cell on
+// little-endian machines, so the table of a memory-mapped file can be
+// used without copying (see Open).
+const (
+	binaryMagic      = "SPTB"
+	binaryVersion    = 1
+	binaryHeaderSize = 24
+	binaryCellSize   = 8
+	binaryCRCSize    = 4
+)
+
+// WriteTo writes the DFA in its binary format to the given writer.
+// It returns the number of written bytes.
+func (d *DFA) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(cw, crc))
+	var buf [binaryHeaderSize]byte
+	copy(buf[:4], binaryMagic)
+	binary.LittleEndian.PutUint32(buf[4:], binaryVersion)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(d.initial))
+	binary.LittleEndian.PutUint64(buf[16:], uint64(len(d.table)))
+	bw.Write(buf[:])
+	for _, cell := range d.table {
+		encodeCell(buf[:binaryCellSize], cell)
+		bw.Write(buf[:binaryCellSize])
+	}
+	if err := bw.Flush(); err != nil {
+		return cw.n, errors.Wrapf(err, "could not write DFA")
+	}
+	binary.LittleEndian.PutUint32(buf[:], crc.Sum32())
+	if _, err := cw.Write(buf[:binaryCRCSize]); err != nil {
+		return cw.n, errors.Wrapf(err, "could not write DFA")
+	}
+	return cw.n, nil
+}
+
+// readChunk is the number of cells that ReadFrom reads at once.
+const readChunk = 4096
+
+// ReadFrom reads a DFA in its binary format from the given reader.
+// It reads exactly the bytes of the DFA, so the DFA can be embedded
+// in a larger stream. It returns the number of read bytes.
+func (d *DFA) ReadFrom(r io.Reader) (int64, error) {
+	crc := crc32.NewIEEE()
+	cr := &countingReader{r: r}
+	tr := io.TeeReader(cr, crc)
+	var buf [binaryHeaderSize]byte
+	if _, err := io.ReadFull(tr, buf[:]); err != nil {
+		return cr.n, errors.Wrapf(err, "could not read DFA header")
+	}
+	initial, n, err := decodeHeader(buf[:])
+	if err != nil {
+		return cr.n, err
+	}
+	table := make([]Cell, 0, min(n, 1<<20))
+	chunk := make([]byte, binaryCellSize*min(n, readChunk))
+	for i := uint64(0); i < n; {
+		m := min(n-i, readChunk)
+		if _, err := io.ReadFull(tr, chunk[:binaryCellSize*m]); err != nil {
+			return cr.n, errors.Wrapf(err, "could not read cell %d", i)
+		}
+		for j := uint64(0); j < m; j, i = j+1, i+1 {
+			cell, err := decodeCell(chunk[binaryCellSize*j:])
+			if err != nil {
+				return cr.n, errors.Wrapf(err, "could not read cell %d", i)
+			}
+			table = append(table, cell)
+		}
+	}
+	if _, err := io.ReadFull(cr, buf[:binaryCRCSize]); err != nil {
+		return cr.n, errors.Wrapf(err, "could not read DFA checksum")
+	}
+	if sum := binary.LittleEndian.Uint32(buf[:]); sum != crc.Sum32() {
+		return cr.n, errors.Errorf("invalid DFA checksum: %x != %x", sum, crc.Sum32())
+	}
+	*d = DFA{table: table, initial: initial}
+	return cr.n, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func binarySize(n int) int64 {
+	return binaryHeaderSize + binaryCellSize*int64(n) + binaryCRCSize
+}
+
+func decodeHeader(buf []byte) (State, uint64, error) {
+	if string(buf[:4]) != binaryMagic {
+		return 0, 0, errors.Errorf("invalid DFA magic: %q", buf[:4])
+	}
+	if v := binary.LittleEndian.Uint32(buf[4:]); v != binaryVersion {
+		return 0, 0, errors.Errorf("unsupported DFA version: %d", v)
+	}
+	initial := int64(binary.LittleEndian.Uint64(buf[8:]))
+	n := binary.LittleEndian.Uint64(buf[16:])
+	if n > 1<<32 {
+		return 0, 0, errors.Errorf("invalid number of cells: %d", n)
+	}
+	return State(initial), n, nil
+}
+
+func encodeCell(buf []byte, cell Cell) {
+	binary.LittleEndian.PutUint32(buf, uint32(cell.data))
+	buf[4] = cell.char
+	buf[5] = cell.next
+	buf[6] = byte(cell.typ)
+	buf[7] = 0
+}
+
+func decodeCell(buf []byte) (Cell, error) {
+	if CellType(buf[6]) > TransitionCell {
+		return Cell{}, errors.Errorf("invalid cell type: %d", buf[6])
+	}
+	return Cell{
+		data: int32(binary.LittleEndian.Uint32(buf)),
+		char: buf[4],
+		next: buf[5],
+		typ:  CellType(buf[6]),
+	}, nil
+}
+
+// decodeBinary decodes a DFA from the complete binary data. If possible,
+// the returned table shares the memory of data.
+func decodeBinary(data []byte) (*DFA, error) {
+	if len(data) < binaryHeaderSize+binaryCRCSize {
+		return nil, errors.New("could not read DFA: truncated data")
+	}
+	initial, n, err := decodeHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) != uint64(binarySize(0))+binaryCellSize*n {
+		return nil, errors.New("could not read DFA: invalid size")
+	}
+	end := len(data) - binaryCRCSize
+	if sum := binary.LittleEndian.Uint32(data[end:]); sum != crc32.ChecksumIEEE(data[:end]) {
+		return nil, errors.Errorf("invalid DFA checksum: %x", sum)
+	}
+	cells := data[binaryHeaderSize:end]
+	for i := 0; i < len(cells); i += binaryCellSize {
+		if CellType(cells[i+6]) > TransitionCell {
+			return nil, errors.Errorf("could not read cell %d: invalid cell type: %d",
+				i/binaryCellSize, cells[i+6])
+		}
+	}
+	if n == 0 {
+		return &DFA{initial: initial}, nil
+	}
+	if nativeCellLayout() {
+		table := unsafe.Slice((*Cell)(unsafe.Pointer(&cells[0])), n)
+		return &DFA{table: table, initial: initial}, nil
+	}
+	table := make([]Cell, n)
+	for i := range table {
+		table[i], _ = decodeCell(cells[i*binaryCellSize:])
+	}
+	return &DFA{table: table, initial: initial}, nil
+}
+
+// nativeCellLayout returns true if the in-memory layout of cells
+// matches the binary format.
+func nativeCellLayout() bool {
+	var c Cell
+	one := uint16(1)
+	return *(*byte)(unsafe.Pointer(&one)) == 1 && // little-endian
+		unsafe.Sizeof(c) == binaryCellSize &&
+		unsafe.Offsetof(c.char) == 4 &&
+		unsafe.Offsetof(c.next) == 5 &&
+		unsafe.Offsetof(c.typ) == 6
+}
+
+// MappedDFA is a DFA whose cell table is memory-mapped from a file.
+// The DFA must not be used after the MappedDFA was closed.
+type MappedDFA struct {
+	*DFA
+	data  []byte
+	close func([]byte) error
+}
+
+// Close releases the mapped memory.
+func (m *MappedDFA) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+	m.DFA = nil
+	return m.close(data)
+}
+
+// DecodeOption is a functional option to configure the decoding of DFAs.
+type DecodeOption func(*decodeConfig)
+
+type decodeConfig struct {
+	validate bool
+}
+
+// WithValidation validates the decoded DFA before it is returned.
+// See DFA.Validate.
+func WithValidation() DecodeOption {
+	return func(c *decodeConfig) {
+		c.validate = true
+	}
+}
+
+func (c decodeConfig) check(d *DFA) error {
+	if !c.validate {
+		return nil
+	}
+	return errors.Wrapf(d.Validate(), "invalid DFA")
+}
+
+func newDecodeConfig(opts []DecodeOption) decodeConfig {
+	var config decodeConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// ReadDFA reads a DFA in its binary format from the given reader.
+func ReadDFA(r io.Reader, opts ...DecodeOption) (*DFA, error) {
+	d := new(DFA)
+	if _, err := d.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	if err := newDecodeConfig(opts).check(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Open opens a file containing a DFA in its binary format. The file is
+// memory-mapped read-only and the DFA uses the mapped cells directly.
+// On systems without memory-mapping support, the file is read into memory.
+func Open(path string, opts ...DecodeOption) (*MappedDFA, error) {
+	data, unmap, err := mmapFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open %s", path)
+	}
+	dfa, err := decodeBinary(data)
+	if err == nil {
+		err = newDecodeConfig(opts).check(dfa)
+	}
+	if err != nil {
+		unmap(data)
+		return nil, errors.Wrapf(err, "could not open %s", path)
+	}
+	return &MappedDFA{DFA: dfa, data: data, close: unmap}, nil
+}
+
+// The binary format of a FST consists of a header, the DFA of the FST
+// in its binary format and the non-zero outputs of its cells followed by
+// a checksum. All numbers are little-endian.
+//
+//	size   content
+//	4      magic "SPTF"
+//	4      format version (uint32)
+//	...    DFA
+//	8      number of outputs m (uint64)
+//	12*m   outputs: cell position (uint32), output (int64)
+//	4      CRC-32 (IEEE) of the outputs section
+//
+// The outputs are stored in increasing order of their positions.
+const (
+	fstMagic      = "SPTF"
+	fstVersion    = 1
+	fstOutputSize = 12
+)
+
+// WriteTo writes the FST in its binary format to the given writer.
+// It returns the number of written bytes.
+func (f *FST) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	var buf [fstOutputSize]byte
+	copy(buf[:4], fstMagic)
+	binary.LittleEndian.PutUint32(buf[4:], fstVersion)
+	if _, err := cw.Write(buf[:8]); err != nil {
+		return cw.n, errors.Wrapf(err, "could not write FST")
+	}
+	if _, err := f.dfa.WriteTo(cw); err != nil {
+		return cw.n, errors.Wrapf(err, "could not write FST")
+	}
+	crc := crc32.NewIEEE()
+	bw := bufio.NewWriter(io.MultiWriter(cw, crc))
+	binary.LittleEndian.PutUint64(buf[:], uint64(len(f.outputs.values)))
+	bw.Write(buf[:8])
+	f.outputs.each(func(i uint32, out int64) {
+		binary.LittleEndian.PutUint32(buf[:], i)
+		binary.LittleEndian.PutUint64(buf[4:], uint64(out))
+		bw.Write(buf[:])
+	})
+	if err := bw.Flush(); err != nil {
+		return cw.n, errors.Wrapf(err, "could not write FST")
+	}
+	binary.LittleEndian.PutUint32(buf[:], crc.Sum32())
+	if _, err := cw.Write(buf[:binaryCRCSize]); err != nil {
+		return cw.n, errors.Wrapf(err, "could not write FST")
+	}
+	return cw.n, nil
+}
+
+// ReadFrom reads a FST in its binary format from the given reader.
+// It returns the number of read bytes.
+func (f *FST) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	var buf [fstOutputSize]byte
+	if _, err := io.ReadFull(cr, buf[:8]); err != nil {
+		return cr.n, errors.Wrapf(err, "could not read FST header")
+	}
+	if string(buf[:4]) != fstMagic {
+		return cr.n, errors.Errorf("invalid FST magic: %q", buf[:4])
+	}
+	if v := binary.LittleEndian.Uint32(buf[4:]); v != fstVersion {
+		return cr.n, errors.Errorf("unsupported FST version: %d", v)
+	}
+	dfa := new(DFA)
+	if _, err := dfa.ReadFrom(cr); err != nil {
+		return cr.n, errors.Wrapf(err, "could not read FST")
+	}
+	crc := crc32.NewIEEE()
+	tr := io.TeeReader(cr, crc)
+	if _, err := io.ReadFull(tr, buf[:8]); err != nil {
+		return cr.n, errors.Wrapf(err, "could not read FST outputs")
+	}
+	m := binary.LittleEndian.Uint64(buf[:])
+	if m > uint64(len(dfa.table)) {
+		return cr.n, errors.Errorf("invalid number of FST outputs: %d", m)
+	}
+	var outputs sparseArray[int64]
+	for i := uint64(0); i < m; i++ {
+		if _, err := io.ReadFull(tr, buf[:]); err != nil {
+			return cr.n, errors.Wrapf(err, "could not read FST output %d", i)
+		}
+		pos := binary.LittleEndian.Uint32(buf[:])
+		out := int64(binary.LittleEndian.Uint64(buf[4:]))
+		if int(pos) >= len(dfa.table) || out == 0 ||
+			(len(outputs.bits) > 0 && pos <= outputs.last()) {
+			return cr.n, errors.Errorf("invalid FST output %d: %d at %d", i, out, pos)
+		}
+		outputs.append(pos, out)
+	}
+	if _, err := io.ReadFull(cr, buf[:binaryCRCSize]); err != nil {
+		return cr.n, errors.Wrapf(err, "could not read FST checksum")
+	}
+	if sum := binary.LittleEndian.Uint32(buf[:]); sum != crc.Sum32() {
+		return cr.n, errors.Errorf("invalid FST checksum: %x != %x", sum, crc.Sum32())
+	}
+	*f = FST{dfa: dfa, outputs: outputs}
+	return cr.n, nil
+}
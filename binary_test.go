@@ -0,0 +1,143 @@
+package sparsetable
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDFABinaryFormat(t *testing.T) {
+	tests := [][]string{
+		{},
+		{""},
+		{"abc", "def", "ghi"},
+		{"für", "yбĸ", "z│ή"},
+		teststrs,
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%v", tc), func(t *testing.T) {
+			dfa := NewDictionary(tc...)
+			buffer := new(bytes.Buffer)
+			n, err := dfa.WriteTo(buffer)
+			if err != nil {
+				t.Fatalf("could not write DFA: %v", err)
+			}
+			if n != int64(buffer.Len()) {
+				t.Fatalf("expected %d written bytes; got %d", buffer.Len(), n)
+			}
+			path := filepath.Join(t.TempDir(), "dfa.bin")
+			if err := os.WriteFile(path, buffer.Bytes(), 0644); err != nil {
+				t.Fatalf("could not write file: %v", err)
+			}
+			got := new(DFA)
+			if m, err := got.ReadFrom(buffer); err != nil || m != n {
+				t.Fatalf("could not read DFA: %d, %v", m, err)
+			}
+			stream := new(bytes.Buffer)
+			for i := 0; i < 2; i++ {
+				if _, err := dfa.WriteTo(stream); err != nil {
+					t.Fatalf("could not write DFA: %v", err)
+				}
+			}
+			stream.WriteString("tail")
+			for i := 0; i < 2; i++ {
+				if m, err := new(DFA).ReadFrom(stream); err != nil || m != n {
+					t.Fatalf("could not read DFA %d from stream: %d, %v", i, m, err)
+				}
+			}
+			if stream.String() != "tail" {
+				t.Fatalf("expected %q after DFAs; got %q", "tail", stream.String())
+			}
+			mapped, err := Open(path)
+			if err != nil {
+				t.Fatalf("could not open DFA: %v", err)
+			}
+			defer mapped.Close()
+			for _, d := range []*DFA{got, mapped.DFA} {
+				if d.initial != dfa.initial || len(d.table) != len(dfa.table) {
+					t.Fatalf("expected %v; got %v", dfa, d)
+				}
+				for i := range dfa.table {
+					if d.table[i] != dfa.table[i] {
+						t.Fatalf("expected cell %s; got %s", dfa.table[i], d.table[i])
+					}
+				}
+				for _, str := range tc {
+					if !d.Contains(str) {
+						t.Fatalf("%q is not accepted", str)
+					}
+				}
+			}
+		})
+	}
+}
+
+type limitedWriter struct {
+	n int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errors.New("limit reached")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestDFABinaryWriteError(t *testing.T) {
+	dfa := NewDictionary(teststrs...)
+	for _, limit := range []int{0, 10, binaryHeaderSize + 3, int(binarySize(len(dfa.table))) - 1} {
+		n, err := dfa.WriteTo(&limitedWriter{n: limit})
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+		if n != int64(limit) {
+			t.Fatalf("expected %d written bytes; got %d", limit, n)
+		}
+	}
+}
+
+func TestInvalidDFABinaryFormat(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	if _, err := NewDictionary("abc", "def").WriteTo(buffer); err != nil {
+		t.Fatalf("could not write DFA: %v", err)
+	}
+	valid := buffer.Bytes()
+	modify := func(i int, b byte) []byte {
+		data := append([]byte{}, valid...)
+		data[i] = b
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated", valid[:len(valid)-1]},
+		{"magic", modify(0, 'X')},
+		{"version", modify(4, 2)},
+		{"checksum", modify(len(valid)-1, valid[len(valid)-1]+1)},
+		{"cell", modify(binaryHeaderSize, valid[binaryHeaderSize]+1)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := new(DFA).ReadFrom(bytes.NewReader(tc.data)); err == nil {
+				t.Fatalf("expected an error")
+			}
+			path := filepath.Join(t.TempDir(), "dfa.bin")
+			if err := os.WriteFile(path, tc.data, 0644); err != nil {
+				t.Fatalf("could not write file: %v", err)
+			}
+			if m, err := Open(path); err == nil {
+				m.Close()
+				t.Fatalf("expected an error")
+			}
+		})
+	}
+}
@@ -3,31 +3,167 @@ package sparsetable
 import (
 	"bytes"
 	"fmt"
+
+	"github.com/pkg/errors"
 )
 
+// DuplicatePolicy decides how to handle repeated keys.
+// It is called with the data of the already added entry and the data of
+// the new entry with the same key and returns the data to keep.
+// If it returns an error, the build fails.
+type DuplicatePolicy func(old, new int32) (int32, error)
+
+// DuplicateError is a duplicate policy that rejects repeated keys.
+func DuplicateError(old, new int32) (int32, error) {
+	return 0, errors.New("duplicate key")
+}
+
+// DuplicateKeepFirst is a duplicate policy that keeps
+// the data of the first entry.
+func DuplicateKeepFirst(old, new int32) (int32, error) {
+	return old, nil
+}
+
+// DuplicateKeepLast is a duplicate policy that keeps
+// the data of the last entry.
+func DuplicateKeepLast(old, new int32) (int32, error) {
+	return new, nil
+}
+
+// DuplicateSum is a duplicate policy that sums up the data of all
+// entries. It returns an error if the sum overflows.
+func DuplicateSum(old, new int32) (int32, error) {
+	sum := int64(old) + int64(new)
+	if sum != int64(int32(sum)) {
+		return 0, errors.Errorf("sum %d + %d overflows", old, new)
+	}
+	return int32(sum), nil
+}
+
+// DuplicateMax is a duplicate policy that keeps
+// the maximal data of all entries.
+func DuplicateMax(old, new int32) (int32, error) {
+	return max(old, new), nil
+}
+
+// DuplicateMin is a duplicate policy that keeps
+// the minimal data of all entries.
+func DuplicateMin(old, new int32) (int32, error) {
+	return min(old, new), nil
+}
+
+// BuilderOption is an option to configure the building of DFAs.
+// Builder options can also be passed to the functions that build DFAs
+// using a Builder, like NewUnsortedBuilder or BuildFromReader.
+type BuilderOption interface {
+	UnsortedOption
+	ReaderOption
+	applyBuilder(*builderConfig)
+}
+
+type builderOption func(*builderConfig)
+
+func (o builderOption) applyBuilder(c *builderConfig)   { o(c) }
+func (o builderOption) applyUnsorted(c *unsortedConfig) { o(&c.builder) }
+func (o builderOption) applyReader(c *readerConfig)     { o(&c.builder) }
+
+type builderConfig struct {
+	duplicates DuplicatePolicy
+	progress   func(Progress)
+	every      int
+}
+
+func newBuilderConfig() builderConfig {
+	return builderConfig{duplicates: DuplicateError}
+}
+
+// WithDuplicatePolicy sets the policy for repeated keys.
+// By default, repeated keys result in an error.
+func WithDuplicatePolicy(p DuplicatePolicy) BuilderOption {
+	return builderOption(func(c *builderConfig) {
+		c.duplicates = p
+	})
+}
+
+// Progress reports the state of a running build.
+type Progress struct {
+	Entries   int     // number of added entries
+	States    int     // number of registered states
+	TableSize int     // number of cells in the sparse table
+	FillRatio float64 // ratio of non-empty cells in the sparse table
+}
+
+// WithProgress sets a callback function that is called after every n
+// added entries and once at the end of the build.
+func WithProgress(n int, f func(Progress)) BuilderOption {
+	return builderOption(func(c *builderConfig) {
+		c.progress = f
+		c.every = max(n, 1)
+	})
+}
+
 // Builder is used to build a DFA.
 type Builder struct {
-	register  map[string]uint32
+	config    builderConfig
+	register  register
 	curstr    []byte
 	curdat    int32
 	tmpStates []TmpState
 	table     SparseTable
+	entries   int
 }
 
 // NewBuilder return a new instance of a Builder.
-func NewBuilder() *Builder {
-	return &Builder{register: make(map[string]uint32)}
+func NewBuilder(opts ...BuilderOption) *Builder {
+	config := newBuilderConfig()
+	for _, opt := range opts {
+		opt.applyBuilder(&config)
+	}
+	return &Builder{config: config}
 }
 
 // Add adds a (string, value) pair to the sparse table. Add returns an error
 // iff the added strings are not in byte-wise lexicographical order.
+// Repeated strings are handled according to the builder's duplicate policy.
 func (b *Builder) Add(str string, data int32) error {
+	if err := b.add(str, data); err != nil {
+		return err
+	}
+	b.entries++
+	if b.config.progress != nil && b.entries%b.config.every == 0 {
+		b.config.progress(b.Progress())
+	}
+	return nil
+}
+
+// Progress returns the current progress of the builder.
+func (b *Builder) Progress() Progress {
+	p := Progress{
+		Entries:   b.entries,
+		States:    b.register.n,
+		TableSize: len(b.table.Cells),
+	}
+	if p.TableSize > 0 {
+		p.FillRatio = float64(b.table.used) / float64(p.TableSize)
+	}
+	return p
+}
+
+func (b *Builder) add(str string, data int32) error {
 	nextstr := []byte(str)
 	if b.curstr == nil {
 		b.curstr = nextstr
 		b.curdat = data
 		return nil
 	}
+	if bytes.Equal(b.curstr, nextstr) {
+		curdat, err := b.config.duplicates(b.curdat, data)
+		if err != nil {
+			return fmt.Errorf("add: %q: %v", nextstr, err)
+		}
+		b.curdat = curdat
+		return nil
+	}
 	if !(bytes.Compare(b.curstr, nextstr) < 0) {
 		return fmt.Errorf("add: not in lexicographical order: %q >= %q",
 			b.curstr, nextstr)
@@ -42,6 +178,9 @@ func (b *Builder) Add(str string, data int32) error {
 
 // Build finishes the building of the automaton and returns it.
 func (b *Builder) Build() *DFA {
+	if b.config.progress != nil {
+		defer func() { b.config.progress(b.Progress()) }()
+	}
 	if b.curstr == nil {
 		return &DFA{}
 	}
@@ -76,12 +215,15 @@ func (b *Builder) insertSuffix(str []byte, prefix int) {
 }
 
 func (b *Builder) replaceOrRegister(tmp TmpState) uint32 {
-	str := tmp.String()
-	if target, ok := b.register[str]; ok {
+	h := hashTmpState(tmp)
+	target, ok := b.register.find(h, func(pos uint32) bool {
+		return equalCells(b.table.Cells, pos, tmp)
+	})
+	if ok {
 		return target
 	}
-	target := b.table.Add(tmp)
-	b.register[str] = target
+	target = b.table.Add(tmp)
+	b.register.insert(h, target)
 	return target
 }
 
@@ -0,0 +1,162 @@
+package sparsetable
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"testing"
+)
+
+func TestBuilderDuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy DuplicatePolicy
+		data   []int32
+		want   int32
+		err    bool
+	}{
+		{"error", DuplicateError, []int32{1, 2}, 0, true},
+		{"keep first", DuplicateKeepFirst, []int32{1, 2, 3}, 1, false},
+		{"keep last", DuplicateKeepLast, []int32{1, 2, 3}, 3, false},
+		{"sum", DuplicateSum, []int32{1, 2, 3}, 6, false},
+		{"sum overflow", DuplicateSum, []int32{math.MaxInt32, 1}, 0, true},
+		{"max", DuplicateMax, []int32{2, 3, 1}, 3, false},
+		{"min", DuplicateMin, []int32{2, 1, 3}, 1, false},
+		{"callback", func(old, new int32) (int32, error) {
+			return old*10 + new, nil
+		}, []int32{1, 2, 3}, 123, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewBuilder(WithDuplicatePolicy(tc.policy))
+			if err := b.Add("a", 0); err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			var err error
+			for _, data := range tc.data {
+				if err = b.Add("key", data); err != nil {
+					break
+				}
+			}
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			if err := b.Add("z", 0); err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			dfa := b.Build()
+			if data, ok := dfa.Lookup("key"); !ok || data != tc.want {
+				t.Fatalf("expected %d, true; got %d, %t", tc.want, data, ok)
+			}
+		})
+	}
+}
+
+func TestBuilderOrder(t *testing.T) {
+	b := NewBuilder(WithDuplicatePolicy(DuplicateKeepFirst))
+	if err := b.Add("b", 1); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if err := b.Add("a", 1); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestNewDictionaryDuplicates(t *testing.T) {
+	dfa := NewDictionary("b", "a", "b", "a")
+	for _, str := range []string{"a", "b"} {
+		if data, ok := dfa.Lookup(str); !ok || data != 1 {
+			t.Fatalf("expected Lookup(%q) = 1, true; got %d, %t", str, data, ok)
+		}
+	}
+}
+
+// makeSyntheticWords generates n sorted, distinct random words.
+func makeSyntheticWords(n int) []string {
+	r := rand.New(rand.NewSource(42))
+	seen := make(map[string]bool, n)
+	words := make([]string, 0, n)
+	for len(words) < n {
+		if word := makeRandomWord(r, 15); !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	sort.Strings(words)
+	return words
+}
+
+// heapObjects returns the bytes occupied by heap objects,
+// including unreachable objects that are not yet collected.
+func heapObjects() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// BenchmarkBuilder reports the build time and the peak heap growth of
+// a build. The heap is sampled every n/100 entries during the build.
+func BenchmarkBuilder(b *testing.B) {
+	for _, n := range []int{10000, 100000} {
+		words := makeSyntheticWords(n)
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				runtime.GC()
+				base := heapObjects()
+				b.StartTimer()
+				builder := NewBuilder(WithProgress(n/100, func(Progress) {
+					if heap := heapObjects(); heap > base {
+						peak = max(peak, heap-base)
+					}
+				}))
+				for j, word := range words {
+					if err := builder.Add(word, int32(j%1000)); err != nil {
+						b.Fatalf("got error: %v", err)
+					}
+				}
+				runtime.KeepAlive(builder.Build())
+			}
+			b.ReportMetric(float64(peak)/(1<<20), "peak-MiB")
+		})
+	}
+}
+
+func TestBuilderProgress(t *testing.T) {
+	var reports []Progress
+	b := NewBuilder(WithProgress(2, func(p Progress) {
+		reports = append(reports, p)
+	}))
+	for _, str := range []string{"a", "b", "c", "d", "e"} {
+		if err := b.Add(str, 1); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+	b.Build()
+	if len(reports) != 3 {
+		t.Fatalf("expected 3 reports; got %d", len(reports))
+	}
+	for i, want := range []int{2, 4, 5} {
+		if reports[i].Entries != want {
+			t.Fatalf("expected %d entries; got %d", want, reports[i].Entries)
+		}
+	}
+	last := reports[len(reports)-1]
+	if last.States == 0 || last.TableSize == 0 {
+		t.Fatalf("expected states and table size; got %+v", last)
+	}
+	if last.FillRatio <= 0 || last.FillRatio > 1 {
+		t.Fatalf("invalid fill ratio: %f", last.FillRatio)
+	}
+}
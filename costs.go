@@ -0,0 +1,189 @@
+package sparsetable
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CostModel defines the costs of the edit operations that are used
+// by the approximate search. All costs must be non-negative.
+type CostModel interface {
+	// Substitution returns the cost to substitute the rune a
+	// of the query string with the rune b of the dictionary entry.
+	Substitution(a, b rune) int
+	// Insertion returns the cost to insert the rune r
+	// of the dictionary entry.
+	Insertion(r rune) int
+	// Deletion returns the cost to delete the rune r
+	// of the query string.
+	Deletion(r rune) int
+}
+
+// TranspositionCostModel is an optional extension of CostModel that
+// defines the costs of transpositions.
+type TranspositionCostModel interface {
+	CostModel
+	// Transposition returns the cost to transpose the adjacent runes
+	// a and b of the query string (ab -> ba).
+	Transposition(a, b rune) int
+}
+
+// UnitCosts is the cost model of the plain Levenshtein distance.
+// Each edit operation costs 1.
+type UnitCosts struct{}
+
+// Substitution returns 1.
+func (UnitCosts) Substitution(a, b rune) int { return 1 }
+
+// Insertion returns 1.
+func (UnitCosts) Insertion(r rune) int { return 1 }
+
+// Deletion returns 1.
+func (UnitCosts) Deletion(r rune) int { return 1 }
+
+// Transposition returns 1.
+func (UnitCosts) Transposition(a, b rune) int { return 1 }
+
+// Rule is a rewrite rule that substitutes the string From of the
+// query string with the string To of the dictionary entry as one
+// single edit operation with the given costs (e.g. "rn" -> "m").
+type Rule struct {
+	From, To string
+	Cost     int
+}
+
+// ConfusionTable is a cost model with explicit costs for
+// single substitutions, insertions and deletions.
+// All other operations cost Default.
+// Additionally a confusion table keeps a list of multi character
+// rewrite rules.
+type ConfusionTable struct {
+	Default int
+	subs    map[[2]rune]int
+	ins     map[rune]int
+	del     map[rune]int
+	rules   []Rule
+}
+
+// NewConfusionTable returns a new empty confusion table with the
+// given default costs.
+func NewConfusionTable(def int) *ConfusionTable {
+	return &ConfusionTable{
+		Default: def,
+		subs:    make(map[[2]rune]int),
+		ins:     make(map[rune]int),
+		del:     make(map[rune]int),
+	}
+}
+
+// SetSubstitution sets the costs for the substitution of the query rune a
+// with the dictionary rune b.
+func (t *ConfusionTable) SetSubstitution(a, b rune, cost int) {
+	t.subs[[2]rune{a, b}] = cost
+}
+
+// SetInsertion sets the costs for the insertion of the dictionary rune r.
+func (t *ConfusionTable) SetInsertion(r rune, cost int) {
+	t.ins[r] = cost
+}
+
+// SetDeletion sets the costs for the deletion of the query rune r.
+func (t *ConfusionTable) SetDeletion(r rune, cost int) {
+	t.del[r] = cost
+}
+
+// AddRule adds a multi character rewrite rule to the confusion table.
+func (t *ConfusionTable) AddRule(rule Rule) {
+	t.rules = append(t.rules, rule)
+}
+
+// Rules returns the multi character rewrite rules of the confusion table.
+// Use WithRules to enable them for a FuzzyDFA.
+func (t *ConfusionTable) Rules() []Rule {
+	return t.rules
+}
+
+// Substitution returns the costs to substitute a with b.
+func (t *ConfusionTable) Substitution(a, b rune) int {
+	if cost, ok := t.subs[[2]rune{a, b}]; ok {
+		return cost
+	}
+	return t.Default
+}
+
+// Insertion returns the costs to insert r.
+func (t *ConfusionTable) Insertion(r rune) int {
+	if cost, ok := t.ins[r]; ok {
+		return cost
+	}
+	return t.Default
+}
+
+// Deletion returns the costs to delete r.
+func (t *ConfusionTable) Deletion(r rune) int {
+	if cost, ok := t.del[r]; ok {
+		return cost
+	}
+	return t.Default
+}
+
+// Transposition returns the default costs.
+func (t *ConfusionTable) Transposition(a, b rune) int {
+	return t.Default
+}
+
+// ReadConfusionTable reads a confusion table with the given default
+// costs. Each line of the input consists of three tab separated
+// fields: the query side, the dictionary side and the costs.
+// An empty query side denotes an insertion, an empty dictionary side
+// denotes a deletion. Lines with more than one character on either side
+// denote rewrite rules. Empty lines and lines starting with # are ignored.
+func ReadConfusionTable(r io.Reader, def int) (*ConfusionTable, error) {
+	t := NewConfusionTable(def)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := t.parse(line); err != nil {
+			return nil, errors.Wrapf(err, "could not read confusion table: line %d", n)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrapf(err, "could not read confusion table")
+	}
+	return t, nil
+}
+
+func (t *ConfusionTable) parse(line string) error {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 3 {
+		return errors.Errorf("invalid line %q: expected 3 fields", line)
+	}
+	cost, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return errors.Wrapf(err, "invalid costs %q", fields[2])
+	}
+	if cost < 0 {
+		return errors.Errorf("invalid costs %d: negative", cost)
+	}
+	from, to := []rune(fields[0]), []rune(fields[1])
+	switch {
+	case len(from) == 1 && len(to) == 1:
+		t.SetSubstitution(from[0], to[0], cost)
+	case len(from) == 0 && len(to) == 1:
+		t.SetInsertion(to[0], cost)
+	case len(from) == 1 && len(to) == 0:
+		t.SetDeletion(from[0], cost)
+	case len(from) > 1 || len(to) > 1:
+		t.AddRule(Rule{From: fields[0], To: fields[1], Cost: cost})
+	default:
+		return errors.Errorf("invalid operation %q -> %q", fields[0], fields[1])
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package sparsetable
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnitCosts(t *testing.T) {
+	var costs UnitCosts
+	if c := costs.Substitution('a', 'b'); c != 1 {
+		t.Fatalf("expected substitution = 1; got %d", c)
+	}
+	if c := costs.Insertion('a'); c != 1 {
+		t.Fatalf("expected insertion = 1; got %d", c)
+	}
+	if c := costs.Deletion('a'); c != 1 {
+		t.Fatalf("expected deletion = 1; got %d", c)
+	}
+}
+
+func TestReadConfusionTable(t *testing.T) {
+	table, err := ReadConfusionTable(strings.NewReader(
+		"# comment\ne\tc\t1\n\nx\to\t5\n\tä\t2\nß\t\t3\nrn\tm\t1\n"), 4)
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	tests := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"e->c", table.Substitution('e', 'c'), 1},
+		{"x->o", table.Substitution('x', 'o'), 5},
+		{"c->e", table.Substitution('c', 'e'), 4},
+		{"insert ä", table.Insertion('ä'), 2},
+		{"insert a", table.Insertion('a'), 4},
+		{"delete ß", table.Deletion('ß'), 3},
+		{"delete s", table.Deletion('s'), 4},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.got != tc.want {
+				t.Fatalf("expected costs = %d; got %d", tc.want, tc.got)
+			}
+		})
+	}
+	want := []Rule{{From: "rn", To: "m", Cost: 1}}
+	if got := table.Rules(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected rules = %v; got %v", want, got)
+	}
+}
+
+func TestReadInvalidConfusionTable(t *testing.T) {
+	tests := []string{
+		"e\tc\n",
+		"e\tc\tx\n",
+		"e\tc\t-1\n",
+		"\t\t1\n",
+	}
+	for _, tc := range tests {
+		t.Run(tc, func(t *testing.T) {
+			if _, err := ReadConfusionTable(strings.NewReader(tc), 1); err == nil {
+				t.Fatalf("expected an error")
+			}
+		})
+	}
+}
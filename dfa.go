@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"encoding/gob"
 	"fmt"
+	"iter"
 	"sort"
+	"strings"
+	"sync"
 	"unicode/utf8"
 
 	"github.com/pkg/errors"
@@ -22,14 +25,17 @@ func (s State) Valid() bool {
 
 // DFA is a DFA implementation using a sparse table.
 type DFA struct {
-	table   []Cell
-	initial State
+	table     []Cell
+	initial   State
+	sizes     sparseArray[uint32] // right language sizes, see Index and Word
+	sizesOnce sync.Once
 }
 
 // NewDictionary builds a minimized sparse table DFA from a list of strings.
+// The data of all strings is 1; repeated strings are ignored.
 // NewDictionary panics if the build process fails.
 func NewDictionary(strs ...string) *DFA {
-	b := NewBuilder()
+	b := NewBuilder(WithDuplicatePolicy(DuplicateKeepFirst))
 	sort.Strings(strs)
 	for _, str := range strs {
 		if err := b.Add(str, 1); err != nil {
@@ -48,7 +54,7 @@ func (d *DFA) Initial() State {
 }
 
 // Delta makes on transition from the given state s with the given byte c.
-func (d DFA) Delta(s State, c byte) State {
+func (d *DFA) Delta(s State, c byte) State {
 	if !d.valid(s, validAnyState) {
 		return -1
 	}
@@ -61,6 +67,50 @@ func (d DFA) Delta(s State, c byte) State {
 	return State(d.table[s+o].Target())
 }
 
+// DeltaString makes the transitions from the given state s with all
+// bytes of the given string.
+func (d *DFA) DeltaString(s State, str string) State {
+	for i := 0; i < len(str) && s.Valid(); i++ {
+		s = d.Delta(s, str[i])
+	}
+	return s
+}
+
+// DeltaBytes makes the transitions from the given state s with all
+// given bytes.
+func (d *DFA) DeltaBytes(s State, bs []byte) State {
+	for i := 0; i < len(bs) && s.Valid(); i++ {
+		s = d.Delta(s, bs[i])
+	}
+	return s
+}
+
+// DeltaRune makes the transitions from the given state s with
+// the UTF-8 encoded bytes of the given rune.
+func (d *DFA) DeltaRune(s State, r rune) State {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return d.DeltaBytes(s, buf[:n])
+}
+
+// Lookup returns (data, true) if the DFA contains the given string.
+// Otherwise it returns (0, false).
+func (d *DFA) Lookup(str string) (int32, bool) {
+	return d.Final(d.DeltaString(d.initial, str))
+}
+
+// LookupBytes returns (data, true) if the DFA contains the given bytes.
+// Otherwise it returns (0, false).
+func (d *DFA) LookupBytes(bs []byte) (int32, bool) {
+	return d.Final(d.DeltaBytes(d.initial, bs))
+}
+
+// Contains returns true iff the DFA contains the given string.
+func (d *DFA) Contains(str string) bool {
+	_, ok := d.Lookup(str)
+	return ok
+}
+
 // Final returns the (data, true) if the given state is final.
 // If the given state is not final, (0, false) is returned.
 func (d *DFA) Final(s State) (int32, bool) {
@@ -89,51 +139,152 @@ var (
 	}
 )
 
+// UTF8Policy defines how malformed UTF-8 sequences are handled
+// while iterating over the UTF-8 transitions of a state.
+// A sequence is malformed if its lead byte is invalid, if one of its
+// following bytes is not a continuation byte, if it ends in a final
+// state before it is complete or if it does not encode a valid rune.
+//
+// Malformed sequences are reported as the transition of their first
+// byte. Subsequent bytes are reported from the reached state, so
+// continuation bytes of a malformed sequence are malformed, too.
+// If a lead byte has both valid and malformed continuations, the valid
+// runes are reported as well as the malformed transition of the lead
+// byte. In this case the words with the valid runes are reachable over
+// both transitions.
+type UTF8Policy int
+
+// The different UTF-8 policies.
+const (
+	// UTF8Error returns an error without calling the callback
+	// function for any transition of the state.
+	UTF8Error UTF8Policy = iota
+	// UTF8Skip ignores malformed sequences.
+	UTF8Skip
+	// UTF8Replace reports malformed sequences as utf8.RuneError.
+	UTF8Replace
+	// UTF8Raw reports malformed sequences with the value of their
+	// first byte as rune (i.e. as if it were Latin-1).
+	UTF8Raw
+)
+
 // EachUTF8Transition iterates over all transition of the given state
 // calling the callback function f for each transition.
 // EachUTF8Transition follows UTF8 mutlibyte sequences to ensure
 // that the callback is called for each valid unicode transition.
+// Malformed sequences are reported as utf8.RuneError (see UTF8Replace).
 func (d *DFA) EachUTF8Transition(s State, f func(rune, State)) {
+	d.EachUTF8TransitionPolicy(s, UTF8Replace, f)
+}
+
+// EachUTF8TransitionPolicy works like EachUTF8Transition, but handles
+// malformed UTF-8 sequences according to the given policy. It only
+// returns an error if the policy is UTF8Error and a malformed sequence
+// is encountered.
+func (d *DFA) EachUTF8TransitionPolicy(s State, policy UTF8Policy, f func(rune, State)) error {
+	return d.eachUTF8Transition(s, policy, func(r rune, _ []byte, t State) {
+		f(r, t)
+	})
+}
+
+// eachUTF8Transition works like EachUTF8TransitionPolicy, but additionally
+// passes the raw bytes of the transition to the callback function.
+// The byte slice is only valid during the call of the callback.
+func (d *DFA) eachUTF8Transition(s State, policy UTF8Policy, f func(rune, []byte, State)) error {
 	if !d.valid(s, validAnyState) {
-		return
+		return nil
+	}
+	if policy == UTF8Error {
+		return d.eachStrictUTF8Transition(s, f)
+	}
+	d.walkUTF8Transitions(s, policy, f)
+	return nil
+}
+
+// eachStrictUTF8Transition collects all transitions of the state and
+// calls f for them only if none of them is malformed.
+func (d *DFA) eachStrictUTF8Transition(s State, f func(rune, []byte, State)) error {
+	type transition struct {
+		r   rune
+		buf [utf8.UTFMax]byte
+		n   int
+		t   State
+	}
+	var transitions []transition
+	err := d.walkUTF8Transitions(s, UTF8Error, func(r rune, bs []byte, t State) {
+		tr := transition{r: r, n: len(bs), t: t}
+		copy(tr.buf[:], bs)
+		transitions = append(transitions, tr)
+	})
+	if err != nil {
+		return err
 	}
+	for _, tr := range transitions {
+		f(tr.r, tr.buf[:tr.n], tr.t)
+	}
+	return nil
+}
+
+// walkUTF8Transitions calls f for each transition of the state
+// until the first malformed sequence if the policy is UTF8Error.
+func (d *DFA) walkUTF8Transitions(s State, policy UTF8Policy, f func(rune, []byte, State)) error {
+	var err error
 	d.forEachTransition(s, func(cell Cell) {
+		if err != nil {
+			return
+		}
 		buf := [utf8.UTFMax]byte{cell.Char()}
-		switch ulen[cell.Char()>>4] {
-		case 0:
-			f(0, State(cell.Target()))
-		case 1:
-			f(rune(cell.Char()), State(cell.Target()))
-		case 2: // two bytes
-			d.forEachUTF8Transition(buf[:], 1, 1, State(cell.Target()), f)
-		case 3: // three bytes
-			d.forEachUTF8Transition(buf[:], 1, 2, State(cell.Target()), f)
-		case 4: // four bytes
-			d.forEachUTF8Transition(buf[:], 1, 3, State(cell.Target()), f)
-		default: // something else
-			panic(fmt.Sprintf("invalid utf8 byte %b encountered", cell.Char()))
+		t := State(cell.Target())
+		n := ulen[cell.Char()>>4]
+		if n == 1 {
+			f(rune(cell.Char()), buf[:1], t)
+			return
+		}
+		if n > 1 && d.forEachUTF8Transition(buf[:], 1, n-1, t, f) {
+			return
+		}
+		switch policy {
+		case UTF8Error:
+			err = errors.Errorf("invalid utf8 sequence at %d: %x", s, cell.Char())
+		case UTF8Replace:
+			f(utf8.RuneError, buf[:1], t)
+		case UTF8Raw:
+			f(rune(cell.Char()), buf[:1], t)
 		}
 	})
+	return err
 }
 
-func (d DFA) forEachUTF8Transition(buf []byte, i, end int, s State, f func(rune, State)) {
+// forEachUTF8Transition calls f for each valid continuation of the
+// sequence in buf[:i]. It returns false if any of the continuations
+// is malformed.
+func (d *DFA) forEachUTF8Transition(buf []byte, i, end int, s State, f func(rune, []byte, State)) bool {
 	if !d.valid(s, validAnyState) {
-		return
+		return false
 	}
+	_, final := d.Final(s)
+	ok := !final
 	d.forEachTransition(s, func(cell Cell) {
-		if !utf8.RuneStart(cell.Char()) {
-			buf[i] = cell.Char()
-			if i == end {
-				r, _ := utf8.DecodeRune(buf)
-				f(r, State(cell.Target()))
-			} else {
-				d.forEachUTF8Transition(buf, i+1, end, State(cell.Target()), f)
-			}
+		if utf8.RuneStart(cell.Char()) {
+			ok = false
+			return
+		}
+		buf[i] = cell.Char()
+		if i < end {
+			ok = d.forEachUTF8Transition(buf, i+1, end, State(cell.Target()), f) && ok
+			return
+		}
+		r, n := utf8.DecodeRune(buf[:end+1])
+		if r == utf8.RuneError && n == 1 {
+			ok = false
+			return
 		}
+		f(r, buf[:end+1], State(cell.Target()))
 	})
+	return ok
 }
 
-func (d DFA) forEachTransition(s State, f func(Cell)) {
+func (d *DFA) forEachTransition(s State, f func(Cell)) {
 	if !d.valid(s, validAnyState) {
 		return
 	}
@@ -146,6 +297,115 @@ func (d DFA) forEachTransition(s State, f func(Cell)) {
 	}
 }
 
+// Walk calls the callback function f for each word of the DFA
+// and its associated data in byte-wise lexicographical order.
+// Walk stops if f returns false.
+func (d *DFA) Walk(f func(string, int32) bool) {
+	d.eachWord(d.initial, nil, func(word []byte, data int32) bool {
+		return f(string(word), data)
+	})
+}
+
+// All returns an iterator over all words of the DFA and their
+// associated data in byte-wise lexicographical order.
+func (d *DFA) All() iter.Seq2[string, int32] {
+	return d.Walk
+}
+
+// eachWord calls the callback function f for each word of the right
+// language of the state s in byte-wise lexicographical order.
+// The words are given as prefix + the according suffix. The byte slice
+// is only valid during the call of the callback. If f returns false,
+// the iteration stops and eachWord returns false.
+func (d *DFA) eachWord(s State, prefix []byte, f func([]byte, int32) bool) bool {
+	if data, final := d.Final(s); final && !f(prefix, data) {
+		return false
+	}
+	ok := true
+	d.forEachTransition(s, func(cell Cell) {
+		ok = ok && d.eachWord(State(cell.Target()), append(prefix, cell.Char()), f)
+	})
+	return ok
+}
+
+// Entry is a word of a DFA and its associated data.
+type Entry struct {
+	Word string
+	Data int32
+}
+
+// Cursor marks the position of a prefix search.
+// The zero value starts a prefix search at the beginning.
+type Cursor struct {
+	last    string
+	started bool
+}
+
+// CursorAfter returns a cursor that resumes a prefix search after
+// the given word. Use it together with Last to pass a cursor to
+// clients and resume the search later.
+func CursorAfter(word string) Cursor {
+	return Cursor{last: word, started: true}
+}
+
+// Last returns the last word that was returned by the prefix search
+// and true. If the search did not return any words yet, ("", false)
+// is returned.
+func (c Cursor) Last() (string, bool) {
+	return c.last, c.started
+}
+
+// WalkPrefix calls the callback function f for each word of the DFA
+// that starts with the given prefix in byte-wise lexicographical order.
+// WalkPrefix stops if f returns false.
+func (d *DFA) WalkPrefix(prefix string, f func(string, int32) bool) {
+	s := d.DeltaString(d.initial, prefix)
+	d.eachWord(s, []byte(prefix), func(word []byte, data int32) bool {
+		return f(string(word), data)
+	})
+}
+
+// PrefixSearch returns the entries of the DFA that start with the given
+// prefix in byte-wise lexicographical order. The search starts after the
+// given cursor. If n > 0, at most n entries are returned. PrefixSearch
+// returns the cursor to resume the search after the last returned entry.
+func (d *DFA) PrefixSearch(prefix string, cursor Cursor, n int) ([]Entry, Cursor) {
+	s := d.DeltaString(d.initial, prefix)
+	var entries []Entry
+	f := func(word []byte, data int32) bool {
+		entries = append(entries, Entry{Word: string(word), Data: data})
+		return n <= 0 || len(entries) < n
+	}
+	switch {
+	case !cursor.started || cursor.last < prefix:
+		d.eachWord(s, []byte(prefix), f)
+	case strings.HasPrefix(cursor.last, prefix):
+		d.eachWordAfter(s, []byte(prefix), []byte(cursor.last[len(prefix):]), f)
+	}
+	if len(entries) > 0 {
+		cursor = CursorAfter(entries[len(entries)-1].Word)
+	}
+	return entries, cursor
+}
+
+// eachWordAfter works like eachWord, but skips all words that are less
+// than or equal to prefix + after.
+func (d *DFA) eachWordAfter(s State, prefix, after []byte, f func([]byte, int32) bool) bool {
+	ok := true
+	d.forEachTransition(s, func(cell Cell) {
+		t, word := State(cell.Target()), append(prefix, cell.Char())
+		switch {
+		case !ok:
+		case len(after) > 0 && cell.Char() < after[0]:
+		case len(after) > 0 && cell.Char() == after[0]:
+			ok = d.eachWordAfter(t, word, after[1:], f)
+		default:
+			ok = d.eachWord(t, word, f)
+		}
+	})
+	return ok
+}
+
 const (
 	validTransition = iota
 	validAnyState
@@ -153,7 +413,7 @@ const (
 	validAny
 )
 
-func (d DFA) valid(s State, typ int) bool {
+func (d *DFA) valid(s State, typ int) bool {
 	if s < 0 || int(s) >= len(d.table) {
 		return false
 	}
@@ -198,8 +458,7 @@ func (d *DFA) GobDecode(bs []byte) error {
 	if err := decoder.Decode(&table); err != nil {
 		return errors.Wrapf(err, "could not GOB decode sparse table")
 	}
-	d.initial = initial
-	d.table = table
+	*d = DFA{initial: initial, table: table}
 	return nil
 }
 
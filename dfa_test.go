@@ -5,6 +5,8 @@ import (
 	"encoding/gob"
 	"fmt"
 	"math/rand"
+	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -98,6 +100,53 @@ func TestEachUTF8Transition(t *testing.T) {
 	}
 }
 
+func TestEachUTF8TransitionPolicy(t *testing.T) {
+	dfa := NewDictionary("a", "über", "\xc3", "\xfcber", "\xe2\x82a")
+	tests := []struct {
+		policy UTF8Policy
+		want   string
+	}{
+		{UTF8Skip, "aü"},
+		{UTF8Replace, "aü\ufffd\ufffd\ufffd"},
+		{UTF8Raw, "aüÃâü"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			var got []rune
+			err := dfa.EachUTF8TransitionPolicy(dfa.Initial(), tc.policy, func(r rune, s State) {
+				if !dfa.CellAt(s).State() {
+					t.Errorf("cell at %d is not a state: %v", s, dfa.CellAt(s))
+				}
+				got = append(got, r)
+			})
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+			want := []rune(tc.want)
+			sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+			if string(got) != string(want) {
+				t.Fatalf("expected %q; got %q", string(want), string(got))
+			}
+		})
+	}
+	var calls int
+	if err := dfa.EachUTF8TransitionPolicy(dfa.Initial(), UTF8Error, func(rune, State) {
+		calls++
+	}); err == nil || calls != 0 {
+		t.Fatalf("expected an error without calls; got %v, %d calls", err, calls)
+	}
+	valid := NewDictionary("a", "über", "民x")
+	if err := valid.EachUTF8TransitionPolicy(valid.Initial(), UTF8Error, func(rune, State) {
+		calls++
+	}); err != nil || calls != 3 {
+		t.Fatalf("expected 3 calls; got %v, %d calls", err, calls)
+	}
+	if got := NewFuzzyDFA(1, dfa).Search("uber", 0); len(got) == 0 || got[0].Str != "über" {
+		t.Fatalf("expected über; got %v", got)
+	}
+}
+
 func TestDFAToGOB(t *testing.T) {
 	tests := [][]string{
 		{"abc", "def", "ghi"},
@@ -184,3 +233,192 @@ func TestFuzzy(t *testing.T) {
 		}
 	}
 }
+
+func TestWalk(t *testing.T) {
+	tests := [][]string{
+		{},
+		{""},
+		{"abc", "def", "ghi"},
+		{"", "a", "ab", "abc", "b", "ba"},
+		{"für", "yбĸ", "z│ή"},
+		teststrs,
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%v", tc), func(t *testing.T) {
+			want := append([]string{}, tc...)
+			sort.Strings(want)
+			dfa := NewDictionary(tc...)
+			got := []string{}
+			dfa.Walk(func(word string, data int32) bool {
+				if data != 1 {
+					t.Fatalf("expected data = 1; got %d", data)
+				}
+				got = append(got, word)
+				return true
+			})
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected %q; got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestAll(t *testing.T) {
+	b := NewBuilder()
+	for i, str := range []string{"a", "ab", "abc", "b", "c"} {
+		if err := b.Add(str, int32(i)); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+	dfa := b.Build()
+	var got []string
+	for word, data := range dfa.All() {
+		if data == 3 {
+			break
+		}
+		got = append(got, fmt.Sprintf("%s:%d", word, data))
+	}
+	want := []string{"a:0", "ab:1", "abc:2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %q; got %q", want, got)
+	}
+}
+
+func TestWalkPrefix(t *testing.T) {
+	dfa := NewDictionary("car", "card", "care", "cars", "cat", "dog", "")
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"car", []string{"car", "card", "care", "cars"}},
+		{"ca", []string{"car", "card", "care", "cars", "cat"}},
+		{"card", []string{"card"}},
+		{"cow", nil},
+		{"", []string{"", "car", "card", "care", "cars", "cat", "dog"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.prefix, func(t *testing.T) {
+			var got []string
+			dfa.WalkPrefix(tc.prefix, func(word string, _ int32) bool {
+				got = append(got, word)
+				return true
+			})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected %q; got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPrefixSearch(t *testing.T) {
+	dfa := NewDictionary("car", "card", "care", "cars", "cat", "dog", "")
+	tests := []struct {
+		prefix string
+		n      int
+		want   [][]string
+	}{
+		{"car", 0, [][]string{{"car", "card", "care", "cars"}}},
+		{"car", 3, [][]string{{"car", "card", "care"}, {"cars"}}},
+		{"ca", 2, [][]string{{"car", "card"}, {"care", "cars"}, {"cat"}}},
+		{"", 4, [][]string{{"", "car", "card", "care"}, {"cars", "cat", "dog"}}},
+		{"x", 4, nil},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tc.prefix, tc.n), func(t *testing.T) {
+			var got [][]string
+			var cursor Cursor
+			for {
+				var entries []Entry
+				entries, cursor = dfa.PrefixSearch(tc.prefix, cursor, tc.n)
+				if len(entries) == 0 {
+					break
+				}
+				// resume from the last word only, like a stateless client
+				last, ok := cursor.Last()
+				if !ok || last != entries[len(entries)-1].Word {
+					t.Fatalf("expected last word %q; got %q, %t",
+						entries[len(entries)-1].Word, last, ok)
+				}
+				cursor = CursorAfter(last)
+				var words []string
+				for _, e := range entries {
+					words = append(words, e.Word)
+				}
+				got = append(got, words)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected %q; got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	b := NewBuilder()
+	strs := []string{"", "abc", "abd", "für", "yбĸ"}
+	for i, str := range strs {
+		if err := b.Add(str, int32(i)); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+	dfa := b.Build()
+	for i, str := range strs {
+		if data, ok := dfa.Lookup(str); !ok || data != int32(i) {
+			t.Errorf("expected Lookup(%q) = %d, true; got %d, %t", str, i, data, ok)
+		}
+		if data, ok := dfa.LookupBytes([]byte(str)); !ok || data != int32(i) {
+			t.Errorf("expected LookupBytes(%q) = %d, true; got %d, %t", str, i, data, ok)
+		}
+		if !dfa.Contains(str) {
+			t.Errorf("expected Contains(%q)", str)
+		}
+	}
+	for _, str := range []string{"a", "ab", "abcd", "fü", "x"} {
+		if _, ok := dfa.Lookup(str); ok {
+			t.Errorf("expected Lookup(%q) to fail", str)
+		}
+		if dfa.Contains(str) {
+			t.Errorf("expected !Contains(%q)", str)
+		}
+	}
+}
+
+func TestDeltaRune(t *testing.T) {
+	dfa := NewDictionary("für", "yбĸ")
+	s := dfa.Initial()
+	for _, r := range "yбĸ" {
+		s = dfa.DeltaRune(s, r)
+	}
+	if _, final := dfa.Final(s); !final {
+		t.Fatalf("expected final state")
+	}
+	if s := dfa.DeltaRune(dfa.Initial(), 'ü'); s.Valid() {
+		t.Fatalf("expected invalid state")
+	}
+	s = dfa.DeltaString(dfa.DeltaRune(dfa.Initial(), 'f'), "ür")
+	if _, final := dfa.Final(s); !final {
+		t.Fatalf("expected final state")
+	}
+}
+
+func benchmarkDictionary() (*DFA, []string) {
+	r := rand.New(rand.NewSource(42))
+	_, strs := makeRandomStrings(1000, r)
+	return NewDictionary(strs...), strs
+}
+
+func BenchmarkLookup(b *testing.B) {
+	dfa, strs := benchmarkDictionary()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dfa.Lookup(strs[i%len(strs)])
+	}
+}
+
+func BenchmarkDeltaLoop(b *testing.B) {
+	dfa, strs := benchmarkDictionary()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		accepts(dfa, strs[i%len(strs)])
+	}
+}
@@ -0,0 +1,160 @@
+package sparsetable
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// DotOption is a functional option to configure WriteDot.
+type DotOption func(*dotConfig)
+
+type dotConfig struct {
+	prefix    string
+	depth     int
+	runes     bool
+	positions bool
+}
+
+// WithDotRunes labels the transitions with UTF-8 runes instead of
+// bytes. Multibyte sequences are merged into one transition.
+// Malformed sequences are labeled with their first byte.
+func WithDotRunes() DotOption {
+	return func(c *dotConfig) {
+		c.runes = true
+	}
+}
+
+// WithDotPositions adds the positions of the state and transition
+// cells in the sparse table to the labels.
+func WithDotPositions() DotOption {
+	return func(c *dotConfig) {
+		c.positions = true
+	}
+}
+
+// WithDotDepth limits the rendered graph to the states that are
+// reachable with at most n transitions from the start state.
+// States whose transitions are cut off are drawn dashed.
+func WithDotDepth(n int) DotOption {
+	return func(c *dotConfig) {
+		c.depth = n
+	}
+}
+
+// WithDotPrefix starts the rendered graph at the state that is
+// reached with the given prefix instead of the initial state.
+func WithDotPrefix(prefix string) DotOption {
+	return func(c *dotConfig) {
+		c.prefix = prefix
+	}
+}
+
+// WriteDot writes the DFA in the Graphviz DOT language to the given
+// writer. The states are numbered in breadth-first order starting with
+// 0 for the start state. Final states are double-circled and labeled
+// with their data. If no word of the DFA starts with the prefix given
+// by WithDotPrefix, the graph is empty.
+func (d *DFA) WriteDot(w io.Writer, opts ...DotOption) error {
+	config := dotConfig{depth: -1}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "digraph dfa {\n\trankdir=LR;\n\tnode [shape=circle];\n")
+	start := d.DeltaString(d.initial, config.prefix)
+	if d.valid(start, validAnyState) {
+		d.writeDot(out, start, config)
+	}
+	fmt.Fprintf(out, "}\n")
+	return errors.Wrapf(out.Flush(), "could not write dot")
+}
+
+func (d *DFA) writeDot(out io.Writer, start State, config dotConfig) {
+	type node struct {
+		state     State
+		id, depth int
+	}
+	ids := map[State]int{start: 0}
+	queue := []node{{state: start}}
+	fmt.Fprintf(out, "\tstart [shape=point];\n\tstart -> s0;\n")
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		label := strconv.Itoa(n.id)
+		if config.positions {
+			label += " @" + strconv.Itoa(int(n.state))
+		}
+		var attrs []string
+		if data, final := d.Final(n.state); final {
+			label += "\n" + strconv.Itoa(int(data))
+			attrs = append(attrs, "shape=doublecircle")
+		}
+		cut := config.depth >= 0 && n.depth >= config.depth
+		if cut && d.table[n.state].Next() > 0 {
+			attrs = append(attrs, "style=dashed")
+		}
+		attrs = append(attrs, "label="+dotQuote(label))
+		fmt.Fprintf(out, "\ts%d [%s];\n", n.id, strings.Join(attrs, ", "))
+		if cut {
+			continue
+		}
+		d.eachDotTransition(n.state, config, func(label string, t State) {
+			id, ok := ids[t]
+			if !ok {
+				id = len(ids)
+				ids[t] = id
+				queue = append(queue, node{state: t, id: id, depth: n.depth + 1})
+			}
+			fmt.Fprintf(out, "\ts%d -> s%d [label=%s];\n", n.id, id, dotQuote(label))
+		})
+	}
+}
+
+func (d *DFA) eachDotTransition(s State, config dotConfig, f func(string, State)) {
+	position := func(label string, c byte) string {
+		if config.positions {
+			return label + " @" + strconv.Itoa(int(s)+int(c))
+		}
+		return label
+	}
+	if !config.runes {
+		d.forEachTransition(s, func(cell Cell) {
+			f(position(dotByte(cell.Char()), cell.Char()), State(cell.Target()))
+		})
+		return
+	}
+	d.eachUTF8Transition(s, UTF8Replace, func(r rune, bs []byte, t State) {
+		label := dotRune(r)
+		if r == utf8.RuneError && len(bs) == 1 {
+			label = dotByte(bs[0])
+		}
+		f(position(label, bs[0]), t)
+	})
+}
+
+func dotByte(c byte) string {
+	if c < utf8.RuneSelf && unicode.IsPrint(rune(c)) {
+		return string(rune(c))
+	}
+	return fmt.Sprintf("0x%02x", c)
+}
+
+func dotRune(r rune) string {
+	if unicode.IsPrint(r) {
+		return string(r)
+	}
+	return fmt.Sprintf("U+%04X", r)
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotQuote(str string) string {
+	return `"` + dotEscaper.Replace(str) + `"`
+}
@@ -0,0 +1,58 @@
+package sparsetable
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteDot(t *testing.T) {
+	dfa := NewDictionary("ab", "b", "ä")
+	tests := []struct {
+		name        string
+		opts        []DotOption
+		contains    []string
+		notContains []string
+	}{
+		{"bytes", nil,
+			[]string{`s0 -> s1 [label="a"]`, `s0 -> s3 [label="0xc3"]`, `s3 -> s2 [label="0xa4"]`,
+				`s2 [shape=doublecircle, label="2\n1"]`},
+			[]string{"ä", "@"}},
+		{"runes", []DotOption{WithDotRunes()},
+			[]string{`s0 -> s2 [label="ä"]`, `s1 -> s2 [label="b"]`},
+			[]string{"0xc3", "s3"}},
+		{"positions", []DotOption{WithDotPositions()},
+			[]string{`[label="0 @` + strconv.Itoa(int(dfa.Initial())) + `"]`,
+				`[label="a @` + strconv.Itoa(int(dfa.Initial())+'a') + `"]`},
+			nil},
+		{"depth", []DotOption{WithDotDepth(1)},
+			[]string{`s1 [style=dashed, label="1"]`},
+			[]string{"s1 -> "}},
+		{"prefix", []DotOption{WithDotPrefix("a")},
+			[]string{`s0 -> s1 [label="b"]`, `s1 [shape=doublecircle, label="1\n1"]`},
+			[]string{"s2"}},
+		{"invalid prefix", []DotOption{WithDotPrefix("x")},
+			[]string{"digraph dfa {"},
+			[]string{"s0"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buffer := new(bytes.Buffer)
+			if err := dfa.WriteDot(buffer, tc.opts...); err != nil {
+				t.Fatalf("could not write dot: %v", err)
+			}
+			got := buffer.String()
+			for _, str := range tc.contains {
+				if !strings.Contains(got, str) {
+					t.Errorf("expected %q in:\n%s", str, got)
+				}
+			}
+			for _, str := range tc.notContains {
+				if strings.Contains(got, str) {
+					t.Errorf("unexpected %q in:\n%s", str, got)
+				}
+			}
+		})
+	}
+}
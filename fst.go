@@ -0,0 +1,250 @@
+package sparsetable
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// FST is a minimal acyclic finite state transducer that maps words
+// to non-negative int64 outputs. The outputs are distributed over the
+// transitions of the transducer and are summed up along the path of
+// a word (additive outputs). Unlike a DFA, equal suffixes of words with
+// different outputs can be shared.
+//
+// The FST uses the sparse table of a DFA for its structure and keeps the
+// non-zero outputs of transition and final state cells in a side table.
+type FST struct {
+	dfa     *DFA
+	outputs sparseArray[int64]
+}
+
+// Get returns the output of the given word and true.
+// If the FST does not contain the word, (0, false) is returned.
+func (f *FST) Get(word string) (int64, bool) {
+	var out int64
+	s := f.dfa.initial
+	for i := 0; i < len(word) && s.Valid(); i++ {
+		t := f.dfa.Delta(s, word[i])
+		if t.Valid() {
+			out += f.outputs.get(uint32(s) + uint32(word[i]))
+		}
+		s = t
+	}
+	if _, final := f.dfa.Final(s); !final {
+		return 0, false
+	}
+	return out + f.outputs.get(uint32(s)), true
+}
+
+// Walk calls the callback function f for each word of the FST and
+// its output in byte-wise lexicographical order.
+// Walk stops if cb returns false.
+func (f *FST) Walk(cb func(string, int64) bool) {
+	f.walk(f.dfa.initial, nil, 0, cb)
+}
+
+func (f *FST) walk(s State, prefix []byte, out int64, cb func(string, int64) bool) bool {
+	if _, final := f.dfa.Final(s); final && !cb(string(prefix), out+f.outputs.get(uint32(s))) {
+		return false
+	}
+	ok := true
+	f.dfa.forEachTransition(s, func(cell Cell) {
+		ok = ok && f.walk(State(cell.Target()), append(prefix, cell.Char()),
+			out+f.outputs.get(uint32(s)+uint32(cell.Char())), cb)
+	})
+	return ok
+}
+
+// DFA returns the underlying DFA of the FST. The data of all final
+// states of the DFA is 0.
+func (f *FST) DFA() *DFA {
+	return f.dfa
+}
+
+type fstTransition struct {
+	char   byte
+	target uint32
+	output int64
+}
+
+type fstState struct {
+	transitions []fstTransition
+	output      int64
+	final       bool
+}
+
+func fnvAdd64(h uint64, x int64) uint64 {
+	return fnvAdd(fnvAdd(h, uint32(x)), uint32(x>>32))
+}
+
+// hash returns the hash of a temporary state.
+func (s fstState) hash() uint64 {
+	h := uint64(fnvOffset)
+	if s.final {
+		h = fnvAdd64(fnvAdd(h, 1), s.output)
+	} else {
+		h = fnvAdd(h, 0)
+	}
+	for _, t := range s.transitions {
+		h = fnvAdd64(fnvAdd(fnvAdd(h, uint32(t.char)), t.target), t.output)
+	}
+	return h
+}
+
+// FSTBuilder is used to build a FST.
+type FSTBuilder struct {
+	register register
+	curstr   []byte
+	states   []fstState
+	arcs     []int64 // outputs of the open transitions into states[i]
+	table    SparseTable
+	outputs  []int64 // outputs of the cells of the table
+	nonempty bool
+}
+
+// NewFSTBuilder returns a new instance of a FSTBuilder.
+func NewFSTBuilder() *FSTBuilder {
+	return &FSTBuilder{
+		states: make([]fstState, 1),
+		arcs:   make([]int64, 1),
+	}
+}
+
+// Add adds a (string, output) pair to the FST. Add returns an error
+// iff the added strings are not in byte-wise lexicographical order
+// or if the output is negative.
+func (b *FSTBuilder) Add(str string, out int64) error {
+	if out < 0 {
+		return fmt.Errorf("add: negative output for %q: %d", str, out)
+	}
+	nextstr := []byte(str)
+	if b.nonempty && !(bytes.Compare(b.curstr, nextstr) < 0) {
+		return fmt.Errorf("add: not in lexicographical order: %q >= %q",
+			b.curstr, nextstr)
+	}
+	prefix := 0
+	if b.nonempty {
+		prefix = commonPrefix(b.curstr, nextstr)
+	}
+	b.freeze(prefix)
+	for i := 1; i <= prefix; i++ {
+		common := min(b.arcs[i], out)
+		if rest := b.arcs[i] - common; rest > 0 {
+			b.pushOutput(i, prefix, rest)
+		}
+		b.arcs[i] = common
+		out -= common
+	}
+	for len(b.states) < len(nextstr)+1 {
+		b.states = append(b.states, fstState{})
+		b.arcs = append(b.arcs, 0)
+	}
+	if len(nextstr) > prefix {
+		b.arcs[prefix+1] = out
+	} else {
+		b.states[prefix].output = out
+	}
+	b.states[len(nextstr)].final = true
+	b.curstr = nextstr
+	b.nonempty = true
+	return nil
+}
+
+// pushOutput pushes the output from the transition into the
+// temporary state i to all outgoing transitions of the state.
+func (b *FSTBuilder) pushOutput(i, prefix int, out int64) {
+	for j := range b.states[i].transitions {
+		b.states[i].transitions[j].output += out
+	}
+	if b.states[i].final {
+		b.states[i].output += out
+	}
+	if i < prefix {
+		b.arcs[i+1] += out
+	}
+}
+
+// freeze registers all temporary states of the current string
+// after the given prefix.
+func (b *FSTBuilder) freeze(prefix int) {
+	if !b.nonempty {
+		return
+	}
+	for i := len(b.curstr); i > prefix; i-- {
+		target := b.replaceOrRegister(b.states[i])
+		b.states[i-1].transitions = append(b.states[i-1].transitions, fstTransition{
+			char:   b.curstr[i-1],
+			target: target,
+			output: b.arcs[i],
+		})
+		b.states[i] = fstState{}
+		b.arcs[i] = 0
+	}
+}
+
+// Build finishes the building of the transducer and returns it.
+func (b *FSTBuilder) Build() *FST {
+	if !b.nonempty {
+		return &FST{dfa: &DFA{}}
+	}
+	b.freeze(0)
+	initial := b.replaceOrRegister(b.states[0])
+	return &FST{
+		dfa:     &DFA{table: b.table.Cells, initial: State(initial)},
+		outputs: newOutputTable(b.outputs),
+	}
+}
+
+func (b *FSTBuilder) replaceOrRegister(s fstState) uint32 {
+	h := s.hash()
+	target, ok := b.register.find(h, func(pos uint32) bool {
+		return b.equal(pos, s)
+	})
+	if ok {
+		return target
+	}
+	tmp := TmpState{Final: s.final}
+	for _, t := range s.transitions {
+		tmp.Transitions = append(tmp.Transitions, TmpStateTransition{char: t.char, target: t.target})
+	}
+	target = b.table.Add(tmp)
+	for len(b.outputs) < len(b.table.Cells) {
+		b.outputs = append(b.outputs, 0)
+	}
+	b.outputs[target] = s.output
+	for _, t := range s.transitions {
+		b.outputs[target+uint32(t.char)] = t.output
+	}
+	b.register.insert(h, target)
+	return target
+}
+
+// equal returns true iff the state at the given position of the table
+// and its outputs equal the temporary state.
+func (b *FSTBuilder) equal(pos uint32, s fstState) bool {
+	cells := b.table.Cells
+	_, final := cells[pos].Final()
+	if final != s.final || b.outputs[pos] != s.output {
+		return false
+	}
+	i := cells[pos].Next()
+	for _, t := range s.transitions {
+		if i == 0 || byte(i) != t.char || cells[pos+i].Target() != t.target ||
+			b.outputs[pos+i] != t.output {
+			return false
+		}
+		i = cells[pos+i].Next()
+	}
+	return i == 0
+}
+
+// newOutputTable returns a sparse array of the non-zero outputs.
+func newOutputTable(outputs []int64) sparseArray[int64] {
+	var t sparseArray[int64]
+	for i, out := range outputs {
+		if out != 0 {
+			t.append(uint32(i), out)
+		}
+	}
+	return t
+}
@@ -0,0 +1,175 @@
+package sparsetable
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestFST(t *testing.T) {
+	tests := []struct {
+		words []string
+		outs  []int64
+	}{
+		{nil, nil},
+		{[]string{""}, []int64{42}},
+		{[]string{"", "a", "ab"}, []int64{3, 1, 7}},
+		{[]string{"mop", "moth", "pop", "star", "stop", "top"}, []int64{0, 1, 2, 3, 4, 5}},
+		{[]string{"abc", "abcd", "abd", "b"}, []int64{10, 2, 10, 0}},
+		{[]string{"für", "yбĸ", "z│ή"}, []int64{1 << 40, 5, 1 << 33}},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%v", tc.words), func(t *testing.T) {
+			b := NewFSTBuilder()
+			for i := range tc.words {
+				if err := b.Add(tc.words[i], tc.outs[i]); err != nil {
+					t.Fatalf("got error: %v", err)
+				}
+			}
+			fst := b.Build()
+			for i, word := range tc.words {
+				if got, ok := fst.Get(word); !ok || got != tc.outs[i] {
+					t.Fatalf("expected Get(%q) = %d, true; got %d, %t",
+						word, tc.outs[i], got, ok)
+				}
+			}
+			var i int
+			fst.Walk(func(word string, out int64) bool {
+				if word != tc.words[i] || out != tc.outs[i] {
+					t.Fatalf("expected %q:%d; got %q:%d", tc.words[i], tc.outs[i], word, out)
+				}
+				i++
+				return true
+			})
+			if i != len(tc.words) {
+				t.Fatalf("expected %d words; got %d", len(tc.words), i)
+			}
+			if _, ok := fst.Get("x"); ok {
+				t.Fatalf("expected Get(%q) to fail", "x")
+			}
+		})
+	}
+}
+
+func TestFSTErrors(t *testing.T) {
+	b := NewFSTBuilder()
+	if err := b.Add("b", 1); err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if err := b.Add("a", 1); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if err := b.Add("b", 1); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if err := b.Add("c", -1); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestRandomFST(t *testing.T) {
+	seed, r := makeR()
+	_, strs := makeRandomStrings(100, r)
+	sort.Strings(strs)
+	b := NewFSTBuilder()
+	outs := make(map[string]int64)
+	for _, str := range strs {
+		outs[str] = r.Int63n(1000)
+		if err := b.Add(str, outs[str]); err != nil {
+			t.Fatalf("got error: %v (%d)", err, seed)
+		}
+	}
+	fst := b.Build()
+	for str, out := range outs {
+		if got, ok := fst.Get(str); !ok || got != out {
+			t.Fatalf("expected Get(%q) = %d, true; got %d, %t (%d)", str, out, got, ok, seed)
+		}
+	}
+}
+
+func TestFSTSharesSuffixes(t *testing.T) {
+	words := []string{"aaaaaaaa", "baaaaaaa", "caaaaaaa", "daaaaaaa"}
+	b := NewBuilder()
+	fb := NewFSTBuilder()
+	for i, word := range words {
+		if err := b.Add(word, int32(i)); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+		if err := fb.Add(word, int64(i)); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+	dfa, fst := b.Build(), fb.Build()
+	if n, m := len(fst.DFA().table), len(dfa.table); n >= m {
+		t.Fatalf("expected FST table (%d) to be smaller than DFA table (%d)", n, m)
+	}
+	if n := len(fst.outputs.values); n >= len(words) {
+		t.Fatalf("expected less than %d stored outputs; got %d", len(words), n)
+	}
+	if n, m := len(fst.outputs.bits), (len(fst.DFA().table)+63)/64; n > m {
+		t.Fatalf("expected at most %d output bit words; got %d", m, n)
+	}
+}
+
+func TestFSTBinaryFormat(t *testing.T) {
+	seed, r := makeR()
+	_, strs := makeRandomStrings(100, r)
+	sort.Strings(strs)
+	b := NewFSTBuilder()
+	outs := make(map[string]int64)
+	for _, str := range strs {
+		if _, ok := outs[str]; ok {
+			continue
+		}
+		outs[str] = r.Int63n(1000) - 1
+		if outs[str] < 0 {
+			outs[str] = 1 << 40
+		}
+		if err := b.Add(str, outs[str]); err != nil {
+			t.Fatalf("got error: %v (%d)", err, seed)
+		}
+	}
+	fst := b.Build()
+	for _, tc := range []struct {
+		fst  *FST
+		outs map[string]int64
+	}{{NewFSTBuilder().Build(), nil}, {fst, outs}} {
+		buffer := new(bytes.Buffer)
+		for i := 0; i < 2; i++ {
+			if _, err := tc.fst.WriteTo(buffer); err != nil {
+				t.Fatalf("could not write FST: %v", err)
+			}
+		}
+		n := int64(buffer.Len() / 2)
+		for i := 0; i < 2; i++ {
+			got := new(FST)
+			if m, err := got.ReadFrom(buffer); err != nil || m != n {
+				t.Fatalf("could not read FST: %d, %v", m, err)
+			}
+			var count int
+			got.Walk(func(str string, out int64) bool {
+				if want, ok := tc.outs[str]; !ok || want != out {
+					t.Fatalf("expected %q:%d; got %q:%d (%d)", str, want, str, out, seed)
+				}
+				count++
+				return true
+			})
+			if count != len(tc.outs) {
+				t.Fatalf("expected %d words; got %d (%d)", len(tc.outs), count, seed)
+			}
+		}
+		if buffer.Len() != 0 {
+			t.Fatalf("expected empty buffer; got %d bytes", buffer.Len())
+		}
+	}
+	buffer := new(bytes.Buffer)
+	if _, err := fst.WriteTo(buffer); err != nil {
+		t.Fatalf("could not write FST: %v", err)
+	}
+	data := buffer.Bytes()
+	data[len(data)-1]++
+	if _, err := new(FST).ReadFrom(bytes.NewReader(data)); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
@@ -1,16 +1,38 @@
 package sparsetable
 
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
 type fuzzyState struct {
 	lev, next int
 	state     State
+	path      []byte
+}
+
+// extend returns a copy of the path of the state extended by the given bytes.
+// The path is always copied, since the states on the stack share the prefixes
+// of their paths.
+func (s fuzzyState) extend(bs ...byte) []byte {
+	path := make([]byte, len(s.path), len(s.path)+len(bs))
+	copy(path, s.path)
+	return append(path, bs...)
 }
 
 // FuzzyStack keeps track of the active states during the apporimxate search.
+// The query string is processed rune by rune, so each edit operation
+// handles one unicode code point on both the query and the dictionary side.
+// Positions in the query string are byte offsets.
 type FuzzyStack struct {
 	stack []fuzzyState
 	dfa   *DFA
+	costs CostModel
+	rules []Rule
 	str   string
 	max   int
+	trans bool
 }
 
 func (f *FuzzyStack) empty() bool {
@@ -28,34 +50,46 @@ func (f *FuzzyStack) pop() fuzzyState {
 }
 
 func (f *FuzzyStack) push(s fuzzyState) {
-	f.dfa.EachUTF8Transition(s.state, func(r rune, t State) {
+	// Costs are non-negative; no insertion can get below the limit again.
+	if s.lev > f.max || !s.state.Valid() {
+		return
+	}
+	f.dfa.eachUTF8Transition(s.state, UTF8Replace, func(r rune, bs []byte, t State) {
 		f.push(fuzzyState{
-			lev:   s.lev + 1,
+			lev:   s.lev + f.costs.Insertion(r),
 			state: t,
 			next:  s.next,
+			path:  s.extend(bs...),
 		})
 	})
-	if s.lev <= f.max && s.next <= len(f.str) && s.state.Valid() {
+	if s.next <= len(f.str) {
 		f.stack = append(f.stack, s)
 	}
 }
 
 func (f *FuzzyStack) deltaDiagonal(s fuzzyState) {
-	f.dfa.EachUTF8Transition(s.state, func(r rune, t State) {
+	if s.next >= len(f.str) {
+		return
+	}
+	q, n := f.rune(s.next)
+	f.dfa.eachUTF8Transition(s.state, UTF8Replace, func(r rune, bs []byte, t State) {
 		f.push(fuzzyState{
-			lev:   s.lev + 1,
+			lev:   s.lev + f.costs.Substitution(q, r),
 			state: t,
-			next:  s.next + 1,
+			next:  s.next + n,
+			path:  s.extend(bs...),
 		})
 	})
 }
 
 func (f *FuzzyStack) deltaVertical(s fuzzyState) {
 	if s.next < len(f.str) {
+		q, n := f.rune(s.next)
 		f.push(fuzzyState{
-			lev:   s.lev + 1,
+			lev:   s.lev + f.costs.Deletion(q),
 			state: s.state,
-			next:  s.next + 1,
+			next:  s.next + n,
+			path:  s.path,
 		})
 	}
 }
@@ -64,36 +98,137 @@ func (f *FuzzyStack) deltaHorizontal(s fuzzyState) {
 	if s.next >= len(f.str) {
 		return
 	}
-	t := f.dfa.Delta(s.state, f.str[s.next])
+	_, n := f.rune(s.next)
+	q := f.str[s.next : s.next+n]
+	t := f.dfa.DeltaString(s.state, q)
 	if !t.Valid() {
 		return
 	}
 	f.push(fuzzyState{
 		lev:   s.lev,
 		state: t,
-		next:  s.next + 1,
+		next:  s.next + n,
+		path:  s.extend([]byte(q)...),
+	})
+}
+
+func (f *FuzzyStack) deltaRules(s fuzzyState) {
+	for _, rule := range f.rules {
+		if !strings.HasPrefix(f.str[s.next:], rule.From) {
+			continue
+		}
+		t := f.dfa.DeltaString(s.state, rule.To)
+		if !t.Valid() {
+			continue
+		}
+		f.push(fuzzyState{
+			lev:   s.lev + rule.Cost,
+			state: t,
+			next:  s.next + len(rule.From),
+			path:  s.extend([]byte(rule.To)...),
+		})
+	}
+}
+
+func (f *FuzzyStack) deltaTransposition(s fuzzyState) {
+	if !f.trans || s.next >= len(f.str) {
+		return
+	}
+	a, n := f.rune(s.next)
+	b, m := f.rune(s.next + n)
+	if m == 0 || a == b {
+		return
+	}
+	ba := f.str[s.next+n:s.next+n+m] + f.str[s.next:s.next+n]
+	t := f.dfa.DeltaString(s.state, ba)
+	if !t.Valid() {
+		return
+	}
+	f.push(fuzzyState{
+		lev:   s.lev + f.transposition(a, b),
+		state: t,
+		next:  s.next + n + m,
+		path:  s.extend([]byte(ba)...),
 	})
 }
 
+func (f *FuzzyStack) transposition(a, b rune) int {
+	if costs, ok := f.costs.(TranspositionCostModel); ok {
+		return costs.Transposition(a, b)
+	}
+	return 1
+}
+
+// rune returns the rune at the given byte position of the query string
+// and its encoded length. Invalid UTF-8 bytes are treated as
+// utf8.RuneError of length 1.
+func (f *FuzzyStack) rune(pos int) (rune, int) {
+	return utf8.DecodeRuneInString(f.str[pos:])
+}
+
 func (f *FuzzyStack) delta(top fuzzyState) {
 	f.deltaDiagonal(top)
 	f.deltaHorizontal(top)
 	f.deltaVertical(top)
+	f.deltaRules(top)
+	f.deltaTransposition(top)
 }
 
 // FuzzyDFA is the basic struct for approximate matching on a DFA.
 type FuzzyDFA struct {
-	dfa *DFA
-	k   int
+	dfa   *DFA
+	costs CostModel
+	rules []Rule
+	k     int
+	trans bool
+}
+
+// FuzzyOption is a functional option to configure a FuzzyDFA.
+type FuzzyOption func(*FuzzyDFA)
+
+// WithCostModel sets the cost model of the edit operations.
+// By default, the FuzzyDFA uses UnitCosts.
+func WithCostModel(costs CostModel) FuzzyOption {
+	return func(d *FuzzyDFA) {
+		d.costs = costs
+	}
+}
+
+// WithRules adds multi character rewrite rules to the approximate search.
+// Each rule is applied as one single edit operation with the rule's costs.
+// Rules with an empty From and To are ignored.
+func WithRules(rules ...Rule) FuzzyOption {
+	return func(d *FuzzyDFA) {
+		for _, rule := range rules {
+			if rule.From != "" || rule.To != "" {
+				d.rules = append(d.rules, rule)
+			}
+		}
+	}
+}
+
+// WithTranspositions enables the transposition of two adjacent runes
+// as one single edit operation (Damerau-Levenshtein distance).
+// If the cost model implements TranspositionCostModel, its costs are used,
+// otherwise each transposition costs 1.
+func WithTranspositions() FuzzyOption {
+	return func(d *FuzzyDFA) {
+		d.trans = true
+	}
 }
 
 // NewFuzzyDFA create a new FuzzyDFA with a given
-// error limit k and a given DFA
-func NewFuzzyDFA(k int, dfa *DFA) *FuzzyDFA {
-	return &FuzzyDFA{k: k, dfa: dfa}
+// error limit k and a given DFA. The error limit k
+// is the maximum cost of the edit operations of a match.
+func NewFuzzyDFA(k int, dfa *DFA, opts ...FuzzyOption) *FuzzyDFA {
+	d := &FuzzyDFA{k: k, dfa: dfa, costs: UnitCosts{}}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
-// MaxError returns the maximum allowed error for the fuzzy DFA.
+// MaxError returns the maximum allowed error (costs) for the fuzzy DFA.
 func (d *FuzzyDFA) MaxError() int {
 	return d.k
 }
@@ -101,9 +236,12 @@ func (d *FuzzyDFA) MaxError() int {
 // Initial returns the initial active states of the approximate match for str.
 func (d *FuzzyDFA) Initial(str string) *FuzzyStack {
 	s := &FuzzyStack{
-		str: str,
-		dfa: d.dfa,
-		max: d.k,
+		str:   str,
+		dfa:   d.dfa,
+		costs: d.costs,
+		rules: d.rules,
+		max:   d.k,
+		trans: d.trans,
 	}
 	s.push(fuzzyState{
 		lev:   0,
@@ -121,13 +259,118 @@ type FinalStateCallback func(int, int, int32)
 // the callback function is called. It returns false if no more transitions
 // can be done with the active stack.
 func (d *FuzzyDFA) Delta(f *FuzzyStack, cb FinalStateCallback) bool {
+	return d.DeltaMatch(f, func(m Match) {
+		cb(m.Lev, m.Pos, m.Data)
+	})
+}
+
+// Match represents a match of an approximate search.
+// Str is the matched dictionary entry, Lev the number of errors,
+// Pos the next position in the query string and Data the data
+// of the final state of the dictionary entry.
+type Match struct {
+	Str  string
+	Lev  int
+	Pos  int
+	Data int32
+}
+
+// MatchCallback is a callback function that is called on final states.
+// It is called with the according match.
+type MatchCallback func(Match)
+
+// DeltaMatch works like Delta, but calls the callback function with
+// the matched dictionary entry if a final state is encountered.
+func (d *FuzzyDFA) DeltaMatch(f *FuzzyStack, cb MatchCallback) bool {
 	if f.empty() {
 		return false
 	}
 	top := f.pop()
 	f.delta(top)
 	if data, final := d.dfa.Final(top.state); final {
-		cb(top.lev, top.next, data)
+		cb(Match{Str: string(top.path), Lev: top.lev, Pos: top.next, Data: data})
 	}
 	return true
 }
+
+// Search returns the distinct dictionary entries that match the
+// whole query string with at most MaxError() errors.
+// Each entry is reported once with its minimal number of errors.
+// The matches are ordered by their number of errors and then
+// lexicographically. If n > 0, at most n matches are returned.
+func (d *FuzzyDFA) Search(query string, n int) []Match {
+	best := make(map[string]Match)
+	s := d.Initial(query)
+	for d.DeltaMatch(s, func(m Match) {
+		if m.Pos != len(query) {
+			return
+		}
+		if old, ok := best[m.Str]; !ok || m.Lev < old.Lev {
+			best[m.Str] = m
+		}
+	}) {
+	}
+	return rankMatches(best, n)
+}
+
+func rankMatches(best map[string]Match, n int) []Match {
+	matches := make([]Match, 0, len(best))
+	for _, m := range best {
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Lev != matches[j].Lev {
+			return matches[i].Lev < matches[j].Lev
+		}
+		return matches[i].Str < matches[j].Str
+	})
+	if n > 0 && len(matches) > n {
+		matches = matches[:n]
+	}
+	return matches
+}
+
+// Complete returns the completions of the query string allowing at most
+// MaxError() errors. The query string is matched approximately against
+// the prefixes of the dictionary entries. All dictionary entries that
+// start with such a prefix are returned. The Lev of a match gives the
+// number of errors of its prefix. The completions are ordered by their
+// number of errors and then lexicographically. If n > 0, at most n
+// completions are returned.
+func (d *FuzzyDFA) Complete(query string, n int) []Match {
+	best := make(map[string]Match)
+	done := make(map[string]int)
+	f := d.Initial(query)
+	for !f.empty() {
+		top := f.pop()
+		f.delta(top)
+		if top.next != len(query) || completed(done, top) {
+			continue
+		}
+		done[string(top.path)] = top.lev
+		d.dfa.eachWord(top.state, top.path, func(word []byte, data int32) bool {
+			if old, ok := best[string(word)]; ok && old.Lev <= top.lev {
+				return true
+			}
+			best[string(word)] = Match{
+				Str:  string(word),
+				Lev:  top.lev,
+				Pos:  top.next,
+				Data: data,
+			}
+			return true
+		})
+	}
+	return rankMatches(best, n)
+}
+
+// completed returns true if the completions of a prefix of the state's
+// path were already enumerated with less or equal errors.
+func completed(done map[string]int, s fuzzyState) bool {
+	for i := len(s.path); i >= 0; i-- {
+		if lev, ok := done[string(s.path[:i])]; ok && lev <= s.lev {
+			return true
+		}
+	}
+	return false
+}
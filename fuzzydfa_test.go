@@ -1,6 +1,8 @@
 package sparsetable
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -157,3 +159,250 @@ func TestUTF8FuzzyDFA(t *testing.T) {
 		})
 	}
 }
+
+func TestFuzzyDFAMatches(t *testing.T) {
+	dfa := NewFuzzyDFA(2, NewDictionary("Bäume", "Baum", "Saum", "match", "Волк"))
+	tests := []struct {
+		test string
+		want map[string]int
+	}{
+		{"Baum", map[string]int{"Baum": 0, "Saum": 1, "Bäume": 2}},
+		{"mxtch", map[string]int{"match": 1}},
+		{"Водк", map[string]int{"Волк": 1}},
+		{"xyz", map[string]int{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.test, func(t *testing.T) {
+			got := make(map[string]int)
+			s := dfa.Initial(tc.test)
+			for dfa.DeltaMatch(s, func(m Match) {
+				if m.Pos != len(tc.test) {
+					return
+				}
+				if k, ok := got[m.Str]; !ok || m.Lev < k {
+					got[m.Str] = m.Lev
+				}
+			}) {
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v; got %v", tc.want, got)
+			}
+			for str, k := range tc.want {
+				if got[str] != k {
+					t.Fatalf("expected %v; got %v", tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFuzzyDFASearch(t *testing.T) {
+	dfa := NewFuzzyDFA(2, NewDictionary("Bäume", "Baum", "Raum", "Saum", "match"))
+	tests := []struct {
+		test string
+		n    int
+		want []Match
+	}{
+		{"Baum", 0, []Match{
+			{Str: "Baum", Lev: 0, Pos: 4, Data: 1},
+			{Str: "Raum", Lev: 1, Pos: 4, Data: 1},
+			{Str: "Saum", Lev: 1, Pos: 4, Data: 1},
+			{Str: "Bäume", Lev: 2, Pos: 4, Data: 1},
+		}},
+		{"Baum", 2, []Match{
+			{Str: "Baum", Lev: 0, Pos: 4, Data: 1},
+			{Str: "Raum", Lev: 1, Pos: 4, Data: 1},
+		}},
+		{"mtch", 1, []Match{
+			{Str: "match", Lev: 1, Pos: 4, Data: 1},
+		}},
+		{"xyz", 0, []Match{}},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tc.test, tc.n), func(t *testing.T) {
+			got := dfa.Search(tc.test, tc.n)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected %v; got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWeightedFuzzyDFA(t *testing.T) {
+	costs := NewConfusionTable(3)
+	costs.SetSubstitution('c', 'e', 1)
+	costs.SetInsertion('e', 2)
+	costs.SetDeletion('x', 1)
+	dfa := NewFuzzyDFA(2, NewDictionary("test", "best"), WithCostModel(costs))
+	tests := []struct {
+		test   string
+		k      int
+		accept bool
+	}{
+		{"test", 0, true},
+		{"tcst", 1, true},
+		{"tcsc", 0, false},
+		{"txst", 0, false},
+		{"tst", 2, true},
+		{"txest", 1, true},
+		{"xtxcst", 0, false},
+		{"bcst", 1, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.test, func(t *testing.T) {
+			final, k := fuzzyAccepts(dfa, tc.test)
+			if final != tc.accept {
+				t.Fatalf("expected accept(%q)=%t; got %t", tc.test, tc.accept, final)
+			}
+			if final && tc.k != k {
+				t.Fatalf("expected accept(%q)=%d; got %d", tc.test, tc.k, k)
+			}
+		})
+	}
+}
+
+func TestRulesFuzzyDFA(t *testing.T) {
+	rules := []Rule{
+		{From: "rn", To: "m", Cost: 1},
+		{From: "cl", To: "d", Cost: 1},
+		{From: "w", To: "vv", Cost: 1},
+	}
+	dfa := NewFuzzyDFA(1, NewDictionary("modern", "dear", "vvave"), WithRules(rules...))
+	tests := []struct {
+		test   string
+		k      int
+		accept bool
+	}{
+		{"modern", 0, true},
+		{"rnodern", 1, true},
+		{"rnodem", 0, false},
+		{"clear", 1, true},
+		{"wave", 1, true},
+		{"waves", 0, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.test, func(t *testing.T) {
+			final, k := fuzzyAccepts(dfa, tc.test)
+			if final != tc.accept {
+				t.Fatalf("expected accept(%q)=%t; got %t", tc.test, tc.accept, final)
+			}
+			if final && tc.k != k {
+				t.Fatalf("expected accept(%q)=%d; got %d", tc.test, tc.k, k)
+			}
+		})
+	}
+}
+
+func TestTranspositionFuzzyDFA(t *testing.T) {
+	dict := NewDictionary("the", "Bäume", "Волк")
+	tests := []struct {
+		test   string
+		trans  bool
+		k      int
+		accept bool
+	}{
+		{"the", true, 0, true},
+		{"teh", true, 1, true},
+		{"teh", false, 2, true},
+		{"hte", true, 1, true},
+		{"eht", true, 2, true},
+		{"hteh", true, 2, true},
+		{"Bäuem", true, 1, true},
+		{"Baüme", true, 2, true},
+		{"Bäuem", false, 2, true},
+		{"Вокл", true, 1, true},
+		{"оВлк", true, 1, true},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s/%t", tc.test, tc.trans), func(t *testing.T) {
+			var opts []FuzzyOption
+			if tc.trans {
+				opts = append(opts, WithTranspositions())
+			}
+			dfa := NewFuzzyDFA(2, dict, opts...)
+			final, k := fuzzyAccepts(dfa, tc.test)
+			if final != tc.accept {
+				t.Fatalf("expected accept(%q)=%t; got %t", tc.test, tc.accept, final)
+			}
+			if final && tc.k != k {
+				t.Fatalf("expected accept(%q)=%d; got %d", tc.test, tc.k, k)
+			}
+		})
+	}
+}
+
+func TestRuneFuzzyDFA(t *testing.T) {
+	dfa := NewFuzzyDFA(2, NewDictionary("Bäume", "Straße", "Волк", "Медведь"))
+	tests := []struct {
+		test   string
+		k      int
+		accept bool
+	}{
+		{"Bume", 1, true},
+		{"Bääume", 1, true},
+		{"Baüme", 2, true},
+		{"Büume", 1, true},
+		{"Bäumeß", 1, true},
+		{"Strasse", 2, true},
+		{"Straß", 1, true},
+		{"Strße", 1, true},
+		{"Stäße", 2, true},
+		{"Вол", 1, true},
+		{"Воллк", 1, true},
+		{"Вклк", 1, true},
+		{"Вк", 2, true},
+		{"Медвед", 1, true},
+		{"Мдвдь", 2, true},
+		{"Мдвд", 0, false},
+		{"Medved", 0, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.test, func(t *testing.T) {
+			final, k := fuzzyAccepts(dfa, tc.test)
+			if final != tc.accept {
+				t.Fatalf("expected accept(%q)=%t; got %t", tc.test, tc.accept, final)
+			}
+			if final && tc.k != k {
+				t.Fatalf("expected accept(%q)=%d; got %d", tc.test, tc.k, k)
+			}
+		})
+	}
+}
+
+func TestFuzzyDFAComplete(t *testing.T) {
+	dfa := NewFuzzyDFA(1, NewDictionary("house", "household", "hose", "mouse", "horse", "zebra"))
+	tests := []struct {
+		test string
+		n    int
+		want []Match
+	}{
+		{"hous", 0, []Match{
+			{Str: "house", Lev: 0, Pos: 4, Data: 1},
+			{Str: "household", Lev: 0, Pos: 4, Data: 1},
+			{Str: "horse", Lev: 1, Pos: 4, Data: 1},
+			{Str: "hose", Lev: 1, Pos: 4, Data: 1},
+			{Str: "mouse", Lev: 1, Pos: 4, Data: 1},
+		}},
+		{"hous", 3, []Match{
+			{Str: "house", Lev: 0, Pos: 4, Data: 1},
+			{Str: "household", Lev: 0, Pos: 4, Data: 1},
+			{Str: "horse", Lev: 1, Pos: 4, Data: 1},
+		}},
+		{"hpuseh", 0, []Match{
+			{Str: "household", Lev: 1, Pos: 6, Data: 1},
+		}},
+		{"", 2, []Match{
+			{Str: "horse", Lev: 0, Pos: 0, Data: 1},
+			{Str: "hose", Lev: 0, Pos: 0, Data: 1},
+		}},
+		{"xyz", 0, []Match{}},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tc.test, tc.n), func(t *testing.T) {
+			got := dfa.Complete(tc.test, tc.n)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected %v; got %v", tc.want, got)
+			}
+		})
+	}
+}
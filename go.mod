@@ -1,3 +1,5 @@
 module "github.com/finkf/sparsetable"
 
+go 1.23
+
 require "github.com/pkg/errors" v0.8.0
@@ -0,0 +1,109 @@
+package sparsetable
+
+// Index returns the rank of the given word in the byte-wise
+// lexicographical ordering of all words of the DFA and true.
+// If the DFA does not contain the word, (0, false) is returned.
+// Index and Word implement a minimal perfect hash function
+// for the words of the DFA.
+func (d *DFA) Index(word string) (int, bool) {
+	sizes := d.rightLanguageSizes()
+	var rank int
+	s := d.initial
+	for i := 0; i < len(word) && s.Valid(); i++ {
+		if _, final := d.Final(s); final {
+			rank++
+		}
+		d.forEachTransition(s, func(cell Cell) {
+			if cell.Char() < word[i] {
+				rank += int(sizes.get(cell.Target()))
+			}
+		})
+		s = d.Delta(s, word[i])
+	}
+	if _, final := d.Final(s); !final {
+		return 0, false
+	}
+	return rank, true
+}
+
+// Word returns the i-th word in the byte-wise lexicographical ordering
+// of all words of the DFA and true. If i is out of range, ("", false)
+// is returned.
+func (d *DFA) Word(i int) (string, bool) {
+	sizes := d.rightLanguageSizes()
+	if i < 0 || !d.valid(d.initial, validAnyState) || i >= int(sizes.get(uint32(d.initial))) {
+		return "", false
+	}
+	var word []byte
+	for s := d.initial; ; {
+		if _, final := d.Final(s); final {
+			if i == 0 {
+				return string(word), true
+			}
+			i--
+		}
+		next := State(-1)
+		d.forEachTransition(s, func(cell Cell) {
+			if next.Valid() {
+				return
+			}
+			if n := int(sizes.get(cell.Target())); i >= n {
+				i -= n
+				return
+			}
+			word = append(word, cell.Char())
+			next = State(cell.Target())
+		})
+		if !next.Valid() {
+			return "", false
+		}
+		s = next
+	}
+}
+
+// rightLanguageSizes returns the number of words in the right language
+// of each reachable state in the DFA. The sizes are computed on first
+// use and are stored in a sparse array that holds 4 bytes per state and
+// 1.5 bits per cell of the table.
+func (d *DFA) rightLanguageSizes() *sparseArray[uint32] {
+	d.sizesOnce.Do(func() {
+		states := make([]uint64, (len(d.table)+63)/64)
+		if d.valid(d.initial, validAnyState) {
+			d.markStates(d.initial, states)
+		}
+		d.sizes = newSparseArray[uint32](states)
+		visited := make([]uint64, len(states))
+		if d.valid(d.initial, validAnyState) {
+			d.computeSize(d.initial, visited)
+		}
+	})
+	return &d.sizes
+}
+
+// markStates marks all states that are reachable from s.
+func (d *DFA) markStates(s State, states []uint64) {
+	if states[s/64]&(1<<(s%64)) != 0 {
+		return
+	}
+	states[s/64] |= 1 << (s % 64)
+	d.forEachTransition(s, func(cell Cell) {
+		d.markStates(State(cell.Target()), states)
+	})
+}
+
+func (d *DFA) computeSize(s State, visited []uint64) uint32 {
+	i, _ := d.sizes.index(uint32(s))
+	if visited[s/64]&(1<<(s%64)) != 0 {
+		return d.sizes.values[i]
+	}
+	var size uint32
+	if _, final := d.Final(s); final {
+		size = 1
+	}
+	d.forEachTransition(s, func(cell Cell) {
+		size += d.computeSize(State(cell.Target()), visited)
+	})
+	visited[s/64] |= 1 << (s % 64)
+	d.sizes.values[i] = size
+	return size
+}
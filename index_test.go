@@ -0,0 +1,62 @@
+package sparsetable
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	tests := [][]string{
+		{},
+		{""},
+		{"a", "ab", "abc", "b", "bc", "c"},
+		{"für", "yбĸ", "z│ή", "abcabc", "ddeabc", "floabc"},
+		teststrs,
+	}
+	for _, tc := range tests {
+		strs := append([]string{}, tc...)
+		sort.Strings(strs)
+		dfa := NewDictionary(strs...)
+		for i, str := range strs {
+			if got, ok := dfa.Index(str); !ok || got != i {
+				t.Errorf("expected Index(%q) = %d, true; got %d, %t", str, i, got, ok)
+			}
+			if got, ok := dfa.Word(i); !ok || got != str {
+				t.Errorf("expected Word(%d) = %q, true; got %q, %t", i, str, got, ok)
+			}
+		}
+		if _, ok := dfa.Word(len(strs)); ok {
+			t.Errorf("expected Word(%d) to fail", len(strs))
+		}
+		if _, ok := dfa.Word(-1); ok {
+			t.Errorf("expected Word(-1) to fail")
+		}
+		if _, ok := dfa.Index("not in dictionary"); ok {
+			t.Errorf("expected Index(%q) to fail", "not in dictionary")
+		}
+		var states int
+		dfa.EachCell(func(cell Cell) {
+			if cell.State() {
+				states++
+			}
+		})
+		if n := len(dfa.sizes.values); n != states {
+			t.Errorf("expected %d sizes; got %d", states, n)
+		}
+	}
+}
+
+func TestRandomIndex(t *testing.T) {
+	seed, r := makeR()
+	_, strs := makeRandomStrings(100, r)
+	sort.Strings(strs)
+	dfa := NewDictionary(strs...)
+	for i, str := range strs {
+		if got, ok := dfa.Index(str); !ok || got != i {
+			t.Fatalf("expected Index(%q) = %d, true; got %d, %t (%d)", str, i, got, ok, seed)
+		}
+		if got, ok := dfa.Word(i); !ok || got != str {
+			t.Fatalf("expected Word(%d) = %q, true; got %q, %t (%d)", i, str, got, ok, seed)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package sparsetable
+
+// Searcher is the common interface of the approximate search engines.
+// It is implemented by FuzzyDFA and LevenshteinDFA.
+type Searcher interface {
+	MaxError() int
+	Search(query string, n int) []Match
+}
+
+// LevenshteinDFA implements the approximate search on a DFA using a
+// Levenshtein automaton (Schulz and Mihov, 2002). The automaton for the
+// query string is computed lazily and is traversed in lockstep with the
+// DFA. The states of the automaton are sets of positions (i, e) meaning
+// that i runes of the query string were consumed with e errors.
+// Subsumed positions are removed, so the number of positions in a state
+// only depends on the error limit and not on the length of the query.
+//
+// A LevenshteinDFA uses unit costs for insertions, deletions and
+// substitutions. It does not support cost models, rewrite rules or
+// transpositions.
+type LevenshteinDFA struct {
+	dfa *DFA
+	k   int
+}
+
+// NewLevenshteinDFA create a new LevenshteinDFA with a given
+// error limit k and a given DFA.
+func NewLevenshteinDFA(k int, dfa *DFA) *LevenshteinDFA {
+	return &LevenshteinDFA{k: k, dfa: dfa}
+}
+
+// MaxError returns the maximum allowed error for the Levenshtein DFA.
+func (d *LevenshteinDFA) MaxError() int {
+	return d.k
+}
+
+// Search returns the distinct dictionary entries that match the
+// whole query string with at most MaxError() errors.
+// The matches are ordered by their number of errors and then
+// lexicographically. If n > 0, at most n matches are returned.
+func (d *LevenshteinDFA) Search(query string, n int) []Match {
+	lev := levAutomaton{query: []rune(query), k: d.k}
+	best := make(map[string]Match)
+	var path []byte
+	var search func(State, levState)
+	search = func(s State, ls levState) {
+		if data, final := d.dfa.Final(s); final {
+			if e, ok := lev.distance(ls); ok {
+				best[string(path)] = Match{
+					Str:  string(path),
+					Lev:  e,
+					Pos:  len(query),
+					Data: data,
+				}
+			}
+		}
+		d.dfa.eachUTF8Transition(s, UTF8Replace, func(r rune, bs []byte, t State) {
+			next := lev.delta(ls, r)
+			if len(next) == 0 {
+				return
+			}
+			path = append(path, bs...)
+			search(t, next)
+			path = path[:len(path)-len(bs)]
+		})
+	}
+	search(d.dfa.Initial(), lev.initial())
+	return rankMatches(best, n)
+}
+
+// levPosition is a position in the Levenshtein automaton:
+// i runes of the query were consumed with e errors.
+type levPosition struct {
+	i, e int
+}
+
+// subsumes returns true if the position p subsumes the position o.
+// Every string that is accepted from o is also accepted from p
+// with less or equal errors.
+func (p levPosition) subsumes(o levPosition) bool {
+	if p.e >= o.e {
+		return false
+	}
+	d := p.i - o.i
+	if d < 0 {
+		d = -d
+	}
+	return d <= o.e-p.e
+}
+
+// levState is a state of the Levenshtein automaton.
+type levState []levPosition
+
+type levAutomaton struct {
+	query []rune
+	k     int
+}
+
+func (l levAutomaton) initial() levState {
+	return l.reduce(levState{{i: 0, e: 0}})
+}
+
+// delta returns the next state after consuming the rune c of the
+// dictionary entry.
+func (l levAutomaton) delta(s levState, c rune) levState {
+	var next levState
+	for _, p := range s {
+		// match after j deletions of runes of the query
+		for j := 0; p.e+j <= l.k && p.i+j < len(l.query); j++ {
+			if l.query[p.i+j] == c {
+				next = append(next, levPosition{i: p.i + j + 1, e: p.e + j})
+				break
+			}
+		}
+		if p.e < l.k {
+			next = append(next, levPosition{i: p.i, e: p.e + 1}) // insertion
+			if p.i < len(l.query) {
+				next = append(next, levPosition{i: p.i + 1, e: p.e + 1}) // substitution
+			}
+		}
+	}
+	return l.reduce(next)
+}
+
+// reduce removes all duplicate and subsumed positions of the state.
+func (l levAutomaton) reduce(s levState) levState {
+	var res levState
+	for j, p := range s {
+		if !l.redundant(s, j, p) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func (l levAutomaton) redundant(s levState, j int, p levPosition) bool {
+	for o, q := range s {
+		if q.subsumes(p) || (o < j && q == p) {
+			return true
+		}
+	}
+	return false
+}
+
+// distance returns the minimal number of errors of the state,
+// if the state is final.
+func (l levAutomaton) distance(s levState) (int, bool) {
+	min := l.k + 1
+	for _, p := range s {
+		if e := p.e + len(l.query) - p.i; e < min {
+			min = e
+		}
+	}
+	return min, min <= l.k
+}
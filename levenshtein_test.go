@@ -0,0 +1,99 @@
+package sparsetable
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestLevenshteinDFA(t *testing.T) {
+	dfa := NewLevenshteinDFA(3, NewDictionary("match", "match two", "Bäume", "Волк"))
+	tests := []struct {
+		test   string
+		k      int
+		accept bool
+	}{
+		{"match", 0, true},
+		{"mxtch", 1, true},
+		{"mxxxh", 3, true},
+		{"ma   tch", 3, true},
+		{"ma   xch", 0, false},
+		{"tc", 3, true},
+		{"t", 0, false},
+		{"mxtc  two", 2, true},
+		{"tchto", 0, false},
+		{"Bume", 1, true},
+		{"Baüme", 2, true},
+		{"Вклк", 1, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.test, func(t *testing.T) {
+			matches := dfa.Search(tc.test, 1)
+			if accept := len(matches) > 0; accept != tc.accept {
+				t.Fatalf("expected accept(%q)=%t; got %t", tc.test, tc.accept, accept)
+			}
+			if tc.accept && matches[0].Lev != tc.k {
+				t.Fatalf("expected accept(%q)=%d; got %d", tc.test, tc.k, matches[0].Lev)
+			}
+		})
+	}
+}
+
+func TestLevenshteinDFAEqualsFuzzyDFA(t *testing.T) {
+	seed, r := makeR()
+	_, strs := makeRandomStrings(50, r)
+	dict := NewDictionary(strs...)
+	for k := 0; k < 3; k++ {
+		engines := []Searcher{NewFuzzyDFA(k, dict), NewLevenshteinDFA(k, dict)}
+		for i := 0; i < 10; i++ {
+			query := strs[r.Intn(len(strs))]
+			if len(query) > 0 && i%2 == 0 { // modify some queries
+				query = query[1:]
+			}
+			want := engines[0].Search(query, 0)
+			got := engines[1].Search(query, 0)
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("k=%d, query=%q: expected %v; got %v (%d)",
+					k, query, want, got, seed)
+			}
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	r := rand.New(rand.NewSource(42))
+	seen := make(map[string]bool)
+	var strs []string
+	for i := 0; i < 1000; i++ {
+		if str := makeRandomWord(r, 12); !seen[str] {
+			seen[str] = true
+			strs = append(strs, str)
+		}
+	}
+	dict := NewDictionary(strs...)
+	for k := 1; k <= 2; k++ {
+		engines := []struct {
+			name   string
+			engine Searcher
+		}{
+			{"FuzzyDFA", NewFuzzyDFA(k, dict)},
+			{"LevenshteinDFA", NewLevenshteinDFA(k, dict)},
+		}
+		for _, e := range engines {
+			b.Run(fmt.Sprintf("%s/k=%d", e.name, k), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					e.engine.Search(strs[i%len(strs)], 0)
+				}
+			})
+		}
+	}
+}
+
+func makeRandomWord(r *rand.Rand, n int) string {
+	word := make([]rune, 1+r.Intn(n))
+	for i := range word {
+		word[i] = chars[r.Intn(26)]
+	}
+	return string(word)
+}
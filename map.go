@@ -0,0 +1,149 @@
+package sparsetable
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Codec encodes and decodes the values of a Map.
+// Equal values must have equal encodings.
+type Codec[V any] interface {
+	Encode(V) ([]byte, error)
+	Decode([]byte) (V, error)
+}
+
+// GobCodec is a Codec that uses encoding/gob.
+type GobCodec[V any] struct{}
+
+// Encode encodes a value to gob.
+func (GobCodec[V]) Encode(v V) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	if err := gob.NewEncoder(buffer).Encode(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Decode decodes a value from gob.
+func (GobCodec[V]) Decode(bs []byte) (V, error) {
+	var v V
+	err := gob.NewDecoder(bytes.NewReader(bs)).Decode(&v)
+	return v, err
+}
+
+// Map maps strings to arbitrary values. The keys are stored in a DFA
+// whose data indexes into a table of deduplicated values.
+type Map[V any] struct {
+	dfa    *DFA
+	values []V
+	codec  Codec[V]
+}
+
+// Get returns the value of the given key and true.
+// If the map does not contain the key, the zero value and false are returned.
+func (m *Map[V]) Get(key string) (V, bool) {
+	i, ok := m.dfa.Lookup(key)
+	if !ok || int(i) >= len(m.values) {
+		var v V
+		return v, false
+	}
+	return m.values[i], true
+}
+
+// DFA returns the underlying DFA of the map. The data of the
+// DFA's final states are indices into the value table of the map.
+func (m *Map[V]) DFA() *DFA {
+	return m.dfa
+}
+
+// Encode writes the map to the given writer.
+// The values are encoded using the map's codec.
+func (m *Map[V]) Encode(w io.Writer) error {
+	values := make([][]byte, len(m.values))
+	for i, v := range m.values {
+		bs, err := m.codec.Encode(v)
+		if err != nil {
+			return errors.Wrapf(err, "could not encode value %d", i)
+		}
+		values[i] = bs
+	}
+	encoder := gob.NewEncoder(w)
+	if err := encoder.Encode(m.dfa); err != nil {
+		return errors.Wrapf(err, "could not encode map")
+	}
+	if err := encoder.Encode(values); err != nil {
+		return errors.Wrapf(err, "could not encode map values")
+	}
+	return nil
+}
+
+// DecodeMap reads a map from the given reader.
+// The values are decoded using the given codec.
+func DecodeMap[V any](r io.Reader, codec Codec[V]) (*Map[V], error) {
+	decoder := gob.NewDecoder(r)
+	dfa := new(DFA)
+	if err := decoder.Decode(dfa); err != nil {
+		return nil, errors.Wrapf(err, "could not decode map")
+	}
+	var values [][]byte
+	if err := decoder.Decode(&values); err != nil {
+		return nil, errors.Wrapf(err, "could not decode map values")
+	}
+	m := &Map[V]{dfa: dfa, values: make([]V, len(values)), codec: codec}
+	for i, bs := range values {
+		v, err := codec.Decode(bs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not decode value %d", i)
+		}
+		m.values[i] = v
+	}
+	return m, nil
+}
+
+// MapBuilder is used to build a Map.
+type MapBuilder[V any] struct {
+	builder *Builder
+	codec   Codec[V]
+	index   map[string]int32
+	values  []V
+}
+
+// NewMapBuilder returns a new instance of a MapBuilder
+// that uses the given codec to deduplicate and encode the values.
+func NewMapBuilder[V any](codec Codec[V]) *MapBuilder[V] {
+	return &MapBuilder[V]{
+		builder: NewBuilder(),
+		codec:   codec,
+		index:   make(map[string]int32),
+	}
+}
+
+// Add adds a (key, value) pair to the map. Add returns an error
+// iff the added keys are not in byte-wise lexicographical order
+// or if the value cannot be encoded.
+func (b *MapBuilder[V]) Add(key string, v V) error {
+	bs, err := b.codec.Encode(v)
+	if err != nil {
+		return errors.Wrapf(err, "add: could not encode value of %q", key)
+	}
+	i, ok := b.index[string(bs)]
+	if !ok {
+		i = int32(len(b.values))
+	}
+	if err := b.builder.Add(key, i); err != nil {
+		return err
+	}
+	if !ok {
+		b.index[string(bs)] = i
+		b.values = append(b.values, v)
+	}
+	return nil
+}
+
+// Build finishes the building of the map and returns it.
+func (b *MapBuilder[V]) Build() *Map[V] {
+	return &Map[V]{dfa: b.builder.Build(), values: b.values, codec: b.codec}
+}
@@ -0,0 +1,56 @@
+package sparsetable
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testPayload struct {
+	Lemma, POS string
+	Freq       int
+}
+
+func TestMap(t *testing.T) {
+	entries := []struct {
+		key   string
+		value testPayload
+	}{
+		{"bank", testPayload{"bank", "NN", 42}},
+		{"banks", testPayload{"bank", "NNS", 7}},
+		{"ran", testPayload{"run", "VBD", 13}},
+		{"run", testPayload{"run", "VB", 13}},
+		{"running", testPayload{"run", "VB", 13}},
+		{"runs", testPayload{"run", "VBZ", 7}},
+	}
+	b := NewMapBuilder[testPayload](GobCodec[testPayload]{})
+	for _, e := range entries {
+		if err := b.Add(e.key, e.value); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+	if err := b.Add("a", testPayload{}); err == nil {
+		t.Fatalf("expected an error")
+	}
+	m := b.Build()
+	if n := len(m.values); n != 5 {
+		t.Fatalf("expected 5 distinct values; got %d", n)
+	}
+	buffer := new(bytes.Buffer)
+	if err := m.Encode(buffer); err != nil {
+		t.Fatalf("could not encode map: %v", err)
+	}
+	decoded, err := DecodeMap[testPayload](buffer, GobCodec[testPayload]{})
+	if err != nil {
+		t.Fatalf("could not decode map: %v", err)
+	}
+	for _, m := range []*Map[testPayload]{m, decoded} {
+		for _, e := range entries {
+			if got, ok := m.Get(e.key); !ok || got != e.value {
+				t.Fatalf("expected Get(%q) = %v, true; got %v, %t", e.key, e.value, got, ok)
+			}
+		}
+		if got, ok := m.Get("runn"); ok || got != (testPayload{}) {
+			t.Fatalf("expected Get(%q) to fail; got %v, %t", "runn", got, ok)
+		}
+	}
+}
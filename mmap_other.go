@@ -0,0 +1,13 @@
+//go:build !unix
+
+package sparsetable
+
+import "os"
+
+func mmapFile(path string) ([]byte, func([]byte) error, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func([]byte) error { return nil }, nil
+}
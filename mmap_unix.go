@@ -0,0 +1,29 @@
+//go:build unix
+
+package sparsetable
+
+import (
+	"os"
+	"syscall"
+)
+
+func mmapFile(path string) ([]byte, func([]byte) error, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() == 0 {
+		return nil, func([]byte) error { return nil }, nil
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()),
+		syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, syscall.Munmap, nil
+}
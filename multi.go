@@ -0,0 +1,124 @@
+package sparsetable
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// MultiDFA maps strings to lists of values. The keys are stored in a
+// DFA whose data indexes into a table of deduplicated value lists.
+type MultiDFA struct {
+	dfa     *DFA
+	offsets []uint32
+	values  []int32
+}
+
+// Lookup returns all values of the given key in the order in which they
+// were added. If the key is not contained, nil is returned.
+// The returned slice must not be modified.
+func (m *MultiDFA) Lookup(key string) []int32 {
+	i, ok := m.dfa.Lookup(key)
+	if !ok {
+		return nil
+	}
+	return m.list(i)
+}
+
+// Walk calls the callback function f for each key and its values in
+// byte-wise lexicographical order. Walk stops if f returns false.
+// The values must not be modified.
+func (m *MultiDFA) Walk(f func(string, []int32) bool) {
+	m.dfa.Walk(func(key string, i int32) bool {
+		return f(key, m.list(i))
+	})
+}
+
+// DFA returns the underlying DFA. The data of the DFA's final states
+// are indices into the list table.
+func (m *MultiDFA) DFA() *DFA {
+	return m.dfa
+}
+
+func (m *MultiDFA) list(i int32) []int32 {
+	if int(i)+1 >= len(m.offsets) {
+		return nil
+	}
+	start, end := m.offsets[i], m.offsets[i+1]
+	return m.values[start:end:end]
+}
+
+// MultiBuilder is used to build a MultiDFA. Unlike Builder, it accepts
+// repeated keys and collects all of their values.
+type MultiBuilder struct {
+	builder *Builder
+	curkey  string
+	curvals []int32
+	index   map[string]int32
+	offsets []uint32
+	values  []int32
+}
+
+// NewMultiBuilder returns a new instance of a MultiBuilder.
+func NewMultiBuilder() *MultiBuilder {
+	return &MultiBuilder{
+		builder: NewBuilder(),
+		index:   make(map[string]int32),
+		offsets: []uint32{0},
+	}
+}
+
+// Add adds a (key, value) pair. The keys must be added in byte-wise
+// lexicographical order, but the same key can be added more than once.
+// Add returns an error iff the keys are not in order.
+func (b *MultiBuilder) Add(key string, data int32) error {
+	if b.curvals != nil && key == b.curkey {
+		b.curvals = append(b.curvals, data)
+		return nil
+	}
+	if b.curvals != nil && key < b.curkey {
+		return fmt.Errorf("add: not in lexicographical order: %q > %q",
+			b.curkey, key)
+	}
+	if err := b.flush(); err != nil {
+		return err
+	}
+	b.curkey = key
+	b.curvals = append(b.curvals[:0], data)
+	return nil
+}
+
+// Build finishes the building and returns the MultiDFA.
+// Build panics if the build process fails.
+func (b *MultiBuilder) Build() *MultiDFA {
+	if err := b.flush(); err != nil {
+		panic(err)
+	}
+	return &MultiDFA{
+		dfa:     b.builder.Build(),
+		offsets: b.offsets,
+		values:  b.values,
+	}
+}
+
+func (b *MultiBuilder) flush() error {
+	if b.curvals == nil {
+		return nil
+	}
+	buf := make([]byte, 4*len(b.curvals))
+	for i, v := range b.curvals {
+		binary.LittleEndian.PutUint32(buf[4*i:], uint32(v))
+	}
+	i, ok := b.index[string(buf)]
+	if !ok {
+		i = int32(len(b.offsets) - 1)
+	}
+	if err := b.builder.Add(b.curkey, i); err != nil {
+		return err
+	}
+	if !ok {
+		b.index[string(buf)] = i
+		b.values = append(b.values, b.curvals...)
+		b.offsets = append(b.offsets, uint32(len(b.values)))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package sparsetable
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMultiDFA(t *testing.T) {
+	b := NewMultiBuilder()
+	for _, e := range []struct {
+		key  string
+		data int32
+	}{
+		{"", 0},
+		{"bank", 1},
+		{"bank", 2},
+		{"bank", 3},
+		{"banks", 1},
+		{"river", 4},
+		{"shore", 1},
+		{"shore", 2},
+		{"shore", 3},
+	} {
+		if err := b.Add(e.key, e.data); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+	if err := b.Add("bank", 5); err == nil {
+		t.Fatalf("expected an error")
+	}
+	m := b.Build()
+	tests := []struct {
+		key  string
+		want []int32
+	}{
+		{"", []int32{0}},
+		{"bank", []int32{1, 2, 3}},
+		{"banks", []int32{1}},
+		{"river", []int32{4}},
+		{"shore", []int32{1, 2, 3}},
+		{"ban", nil},
+		{"x", nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.key, func(t *testing.T) {
+			if got := m.Lookup(tc.key); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected %v; got %v", tc.want, got)
+			}
+		})
+	}
+	if n := len(m.offsets) - 1; n != 4 {
+		t.Fatalf("expected 4 distinct lists; got %d", n)
+	}
+	var keys []string
+	m.Walk(func(key string, values []int32) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if want := []string{"", "bank", "banks", "river", "shore"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected %q; got %q", want, keys)
+	}
+}
+
+func TestEmptyMultiDFA(t *testing.T) {
+	m := NewMultiBuilder().Build()
+	if got := m.Lookup(""); got != nil {
+		t.Fatalf("expected nil; got %v", got)
+	}
+}
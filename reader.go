@@ -0,0 +1,119 @@
+package sparsetable
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LineFormat defines the format of the lines read by BuildFromReader.
+type LineFormat int
+
+// Supported line formats.
+const (
+	// WordLines are lines that consist of a single word.
+	// The data of all words is 1.
+	WordLines LineFormat = iota
+	// ValueLines are lines that consist of a word and its integer data
+	// separated by the separator (a tab by default). The data is taken
+	// from the text after the last separator of the line.
+	ValueLines
+)
+
+// ReaderOption is an option to configure BuildFromReader.
+// All BuilderOptions are ReaderOptions, too.
+type ReaderOption interface {
+	applyReader(*readerConfig)
+}
+
+type readerOption func(*readerConfig)
+
+func (o readerOption) applyReader(c *readerConfig) { o(c) }
+
+type readerConfig struct {
+	builder builderConfig
+	format  LineFormat
+	sep     string
+}
+
+// WithLineFormat sets the format of the lines read by BuildFromReader.
+// By default, WordLines is used.
+func WithLineFormat(format LineFormat) ReaderOption {
+	return readerOption(func(c *readerConfig) {
+		c.format = format
+	})
+}
+
+// WithSeparator sets the separator between words and data for ValueLines.
+// By default, a tab is used.
+func WithSeparator(sep string) ReaderOption {
+	return readerOption(func(c *readerConfig) {
+		c.sep = sep
+	})
+}
+
+// checkContext is the number of entries after which
+// a running build checks its context.
+const checkContext = 1024
+
+// maxLineLength is the maximal length of a line read by BuildFromReader.
+const maxLineLength = 1 << 20
+
+// BuildFromReader builds a DFA from the lines of the given reader.
+// Each line contains one entry; the entries must be in byte-wise
+// lexicographical order. Empty lines are ignored. The returned errors
+// contain the line number of the offending entry.
+func BuildFromReader(r io.Reader, opts ...ReaderOption) (*DFA, error) {
+	return BuildFromReaderContext(context.Background(), r, opts...)
+}
+
+// BuildFromReaderContext works like BuildFromReader, but aborts the
+// build if the given context is done.
+func BuildFromReaderContext(ctx context.Context, r io.Reader, opts ...ReaderOption) (*DFA, error) {
+	config := readerConfig{builder: newBuilderConfig(), sep: "\t"}
+	for _, opt := range opts {
+		opt.applyReader(&config)
+	}
+	b := &Builder{config: config.builder}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxLineLength)
+	for n := 1; s.Scan(); n++ {
+		if n%checkContext == 0 && ctx.Err() != nil {
+			return nil, errors.Wrapf(ctx.Err(), "line %d", n)
+		}
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		word, data, err := config.parseLine(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", n)
+		}
+		if err := b.Add(word, data); err != nil {
+			return nil, errors.Wrapf(err, "line %d", n)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrapf(err, "could not read entries")
+	}
+	return b.Build(), nil
+}
+
+func (c *readerConfig) parseLine(line string) (string, int32, error) {
+	if c.format == WordLines {
+		return line, 1, nil
+	}
+	i := strings.LastIndex(line, c.sep)
+	if i < 0 {
+		return "", 0, errors.Errorf("missing separator %q in %q", c.sep, line)
+	}
+	data, err := strconv.ParseInt(line[i+len(c.sep):], 10, 32)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "invalid data in %q", line)
+	}
+	return line[:i], int32(data), nil
+}
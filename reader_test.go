@@ -0,0 +1,90 @@
+package sparsetable
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestBuildFromReader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []ReaderOption
+		want  []Entry
+	}{
+		{"words", "a\nab\n\nb\r\n", nil,
+			[]Entry{{"a", 1}, {"ab", 1}, {"b", 1}}},
+		{"tsv", "a\t3\nb c\t-1\nd\te\t42\n", []ReaderOption{WithLineFormat(ValueLines)},
+			[]Entry{{"a", 3}, {"b c", -1}, {"d\te", 42}}},
+		{"separator", "a;;3\nb;;4\n", []ReaderOption{WithLineFormat(ValueLines), WithSeparator(";;")},
+			[]Entry{{"a", 3}, {"b", 4}}},
+		{"duplicates", "a\t3\na\t4\n", []ReaderOption{WithLineFormat(ValueLines),
+			WithDuplicatePolicy(DuplicateSum)}, []Entry{{"a", 7}}},
+		{"empty", "", nil, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dfa, err := BuildFromReader(strings.NewReader(tc.input), tc.opts...)
+			if err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+			var got []Entry
+			dfa.Walk(func(word string, data int32) bool {
+				got = append(got, Entry{Word: word, Data: data})
+				return true
+			})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected %v; got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBuildFromReaderErrors(t *testing.T) {
+	tests := []struct {
+		name, input, line string
+		opts              []ReaderOption
+	}{
+		{"order", "a\nc\nb\n", "line 3", nil},
+		{"duplicate", "a\nb\nb\n", "line 3", nil},
+		{"missing separator", "a\t1\nb\n", "line 2", []ReaderOption{WithLineFormat(ValueLines)}},
+		{"invalid data", "a\t1\n\nb\tx\n", "line 3", []ReaderOption{WithLineFormat(ValueLines)}},
+		{"overflow", "a\t9999999999\n", "line 1", []ReaderOption{WithLineFormat(ValueLines)}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := BuildFromReader(strings.NewReader(tc.input), tc.opts...)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if !strings.Contains(err.Error(), tc.line) {
+				t.Fatalf("expected error to contain %q; got %q", tc.line, err)
+			}
+		})
+	}
+}
+
+func TestBuildFromReaderContext(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 2*checkContext; i++ {
+		fmt.Fprintf(&input, "%08d\n", i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := BuildFromReaderContext(ctx, strings.NewReader(input.String()))
+	if errors.Cause(err) != context.Canceled {
+		t.Fatalf("expected %v; got %v", context.Canceled, err)
+	}
+	dfa, err := BuildFromReaderContext(context.Background(), strings.NewReader(input.String()))
+	if err != nil {
+		t.Fatalf("got error: %v", err)
+	}
+	if !dfa.Contains("00000042") {
+		t.Fatalf("expected dfa to contain %q", "00000042")
+	}
+}
@@ -0,0 +1,109 @@
+package sparsetable
+
+// register is an open addressing hash table of the registered states
+// of a sparse table. It only stores the positions of the states and
+// 32 bits of their hashes; lookups compare the temporary states
+// structurally against the cells of the table.
+type register struct {
+	slots []uint64 // hash key << 32 | position + 1; 0 marks empty slots
+	n     int
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+func fnvAdd(h uint64, x uint32) uint64 {
+	for i := 0; i < 4; i++ {
+		h ^= uint64(byte(x >> (8 * i)))
+		h *= fnvPrime
+	}
+	return h
+}
+
+// hashTmpState returns the hash of a temporary state.
+func hashTmpState(tmp TmpState) uint64 {
+	h := uint64(fnvOffset)
+	if tmp.Final {
+		h = fnvAdd(fnvAdd(h, 1), uint32(tmp.Data))
+	} else {
+		h = fnvAdd(h, 0)
+	}
+	for _, t := range tmp.Transitions {
+		h = fnvAdd(fnvAdd(h, uint32(t.char)), t.target)
+	}
+	return h
+}
+
+// equalCells returns true iff the state at the given position of the
+// table equals the temporary state.
+func equalCells(cells []Cell, pos uint32, tmp TmpState) bool {
+	data, final := cells[pos].Final()
+	if final != tmp.Final || (final && data != tmp.Data) {
+		return false
+	}
+	i := cells[pos].Next()
+	for _, t := range tmp.Transitions {
+		if i == 0 || byte(i) != t.char || cells[pos+i].Target() != t.target {
+			return false
+		}
+		i = cells[pos+i].Next()
+	}
+	return i == 0
+}
+
+// find returns the position of a registered state with the hash h
+// for which equal returns true.
+func (r *register) find(h uint64, equal func(pos uint32) bool) (uint32, bool) {
+	if len(r.slots) == 0 {
+		return 0, false
+	}
+	key := registerKey(h)
+	mask := uint32(len(r.slots) - 1)
+	for i := key & mask; r.slots[i] != 0; i = (i + 1) & mask {
+		if uint32(r.slots[i]>>32) != key {
+			continue
+		}
+		if pos := uint32(r.slots[i]) - 1; equal(pos) {
+			return pos, true
+		}
+	}
+	return 0, false
+}
+
+// insert registers the state at the given position with the hash h.
+func (r *register) insert(h uint64, pos uint32) {
+	if 4*(r.n+1) > 3*len(r.slots) {
+		r.grow()
+	}
+	r.put(uint64(registerKey(h))<<32 | uint64(pos+1))
+	r.n++
+}
+
+func registerKey(h uint64) uint32 {
+	return uint32(h ^ h>>32)
+}
+
+func (r *register) put(slot uint64) {
+	mask := uint32(len(r.slots) - 1)
+	i := uint32(slot>>32) & mask
+	for r.slots[i] != 0 {
+		i = (i + 1) & mask
+	}
+	r.slots[i] = slot
+}
+
+func (r *register) grow() {
+	old := r.slots
+	n := 2 * len(old)
+	if n == 0 {
+		n = 1024
+	}
+	r.slots = make([]uint64, n)
+	for _, slot := range old {
+		if slot != 0 {
+			r.put(slot)
+		}
+	}
+}
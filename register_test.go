@@ -0,0 +1,57 @@
+package sparsetable
+
+import "testing"
+
+func findTmpState(r *register, st *SparseTable, tmp TmpState) (uint32, bool) {
+	return r.find(hashTmpState(tmp), func(pos uint32) bool {
+		return equalCells(st.Cells, pos, tmp)
+	})
+}
+
+func TestRegister(t *testing.T) {
+	var st SparseTable
+	var r register
+	states := []TmpState{
+		{Final: true, Data: 42},
+		{Final: true, Data: 43},
+		{Final: false},
+		{Transitions: []TmpStateTransition{{'a', 0}, {'c', 1}}},
+		{Transitions: []TmpStateTransition{{'a', 0}, {'c', 2}}},
+		{Transitions: []TmpStateTransition{{'a', 0}}},
+		{Final: true, Data: 42, Transitions: []TmpStateTransition{{'a', 0}, {'c', 1}}},
+	}
+	var pos []uint32
+	for _, tmp := range states {
+		if _, ok := findTmpState(&r, &st, tmp); ok {
+			t.Fatalf("expected %v not to be registered", tmp)
+		}
+		p := st.Add(tmp)
+		if !equalCells(st.Cells, p, tmp) {
+			t.Fatalf("expected %v to equal the cells at %d", tmp, p)
+		}
+		r.insert(hashTmpState(tmp), p)
+		pos = append(pos, p)
+	}
+	for i, tmp := range states {
+		if p, ok := findTmpState(&r, &st, tmp); !ok || p != pos[i] {
+			t.Fatalf("expected find(%v) = %d, true; got %d, %t", tmp, pos[i], p, ok)
+		}
+	}
+}
+
+func TestRegisterGrow(t *testing.T) {
+	var st SparseTable
+	var r register
+	var pos []uint32
+	for i := 0; i < 5000; i++ {
+		tmp := TmpState{Final: true, Data: int32(i)}
+		p := st.Add(tmp)
+		r.insert(hashTmpState(tmp), p)
+		pos = append(pos, p)
+	}
+	for i := range pos {
+		if p, ok := findTmpState(&r, &st, TmpState{Final: true, Data: int32(i)}); !ok || p != pos[i] {
+			t.Fatalf("expected find(%d) = %d, true; got %d, %t", i, pos[i], p, ok)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package sparsetable
+
+import "math/bits"
+
+// sparseArray maps a subset of the cells of a table to values.
+// A bit set marks the cells with values; the values of the marked
+// cells are stored densely in the order of the cells. Besides the
+// values, a sparse array needs 1.5 bits per cell.
+type sparseArray[V any] struct {
+	bits   []uint64 // bit i is set iff cell i has a value
+	ranks  []uint32 // number of set bits in bits[:i]
+	values []V
+}
+
+// newSparseArray returns a sparse array for the cells marked in the
+// given bit set. The values of all cells are zero.
+func newSparseArray[V any](set []uint64) sparseArray[V] {
+	a := sparseArray[V]{bits: set, ranks: make([]uint32, len(set))}
+	var n int
+	for i, word := range set {
+		a.ranks[i] = uint32(n)
+		n += bits.OnesCount64(word)
+	}
+	a.values = make([]V, n)
+	return a
+}
+
+// append sets the value of the cell i. The cells must be
+// appended in increasing order.
+func (a *sparseArray[V]) append(i uint32, v V) {
+	for uint32(len(a.bits)) <= i/64 {
+		a.ranks = append(a.ranks, uint32(len(a.values)))
+		a.bits = append(a.bits, 0)
+	}
+	a.bits[i/64] |= 1 << (i % 64)
+	a.values = append(a.values, v)
+}
+
+// index returns the index of the value of the cell i and true.
+// If the cell has no value, (0, false) is returned.
+func (a *sparseArray[V]) index(i uint32) (int, bool) {
+	w := i / 64
+	if int(w) >= len(a.bits) {
+		return 0, false
+	}
+	bit := uint64(1) << (i % 64)
+	if a.bits[w]&bit == 0 {
+		return 0, false
+	}
+	return int(a.ranks[w]) + bits.OnesCount64(a.bits[w]&(bit-1)), true
+}
+
+// get returns the value of the cell i or the zero value
+// if the cell has no value.
+func (a *sparseArray[V]) get(i uint32) V {
+	if j, ok := a.index(i); ok {
+		return a.values[j]
+	}
+	var v V
+	return v
+}
+
+// last returns the last cell with a value.
+// The array must not be empty.
+func (a *sparseArray[V]) last() uint32 {
+	w := len(a.bits) - 1
+	return uint32(w)*64 + 63 - uint32(bits.LeadingZeros64(a.bits[w]))
+}
+
+// each calls f for each cell with a value in increasing order.
+func (a *sparseArray[V]) each(f func(uint32, V)) {
+	for w, word := range a.bits {
+		for j := a.ranks[w]; word != 0; j++ {
+			f(uint32(w)*64+uint32(bits.TrailingZeros64(word)), a.values[j])
+			word &= word - 1
+		}
+	}
+}
@@ -12,7 +12,7 @@ type TmpStateTransition struct {
 }
 
 // String returns a strin representation for a temporary state
-// transition. It is used basically for hashing.
+// transition.
 func (t TmpStateTransition) String() string {
 	return fmt.Sprintf("%c %d", t.char, t.target)
 }
@@ -26,7 +26,6 @@ type TmpState struct {
 }
 
 // String returns a strin representation for a temporary state.
-// It is used basically for hashing.
 func (t TmpState) String() string {
 	return fmt.Sprintf("%t %d %v", t.Final, t.Data, t.Transitions)
 }
@@ -35,6 +34,7 @@ func (t TmpState) String() string {
 type SparseTable struct {
 	Cells []Cell
 	free  uint32
+	used  int // number of non-empty cells
 }
 
 // Add adds a temporary state into the sparse table. It returns the
@@ -48,6 +48,7 @@ func (t *SparseTable) Add(tmp TmpState) uint32 {
 }
 
 func (t *SparseTable) doInsert(i uint32, tmp TmpState) {
+	t.used += 1 + len(tmp.Transitions)
 	var next byte
 	if len(tmp.Transitions) > 0 {
 		next = tmp.Transitions[0].char
@@ -97,7 +98,16 @@ func (t *SparseTable) fits(i uint32, tmp TmpState) bool {
 	return true
 }
 
+// maxFreeWindow is the maximal distance between the first cell that is
+// searched for new states and the end of the table. Empty cells further
+// away from the end are given up, since they are rarely usable for new
+// states and scanning them makes the insertion quadratic.
+const maxFreeWindow = 1024
+
 func (t *SparseTable) nextFreeCell() {
+	if n := uint32(len(t.Cells)); n > maxFreeWindow && t.free < n-maxFreeWindow {
+		t.free = n - maxFreeWindow
+	}
 	for {
 		if uint32(len(t.Cells)) <= t.free {
 			t.Cells = append(t.Cells, Cell{})
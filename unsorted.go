@@ -0,0 +1,258 @@
+package sparsetable
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// UnsortedOption is an option to configure an UnsortedBuilder.
+// All BuilderOptions are UnsortedOptions, too.
+type UnsortedOption interface {
+	applyUnsorted(*unsortedConfig)
+}
+
+type unsortedOption func(*unsortedConfig)
+
+func (o unsortedOption) applyUnsorted(c *unsortedConfig) { o(c) }
+
+type unsortedConfig struct {
+	builder builderConfig
+	memory  int
+	tmpdir  string
+}
+
+// WithMemoryBudget sets the approximate number of bytes an UnsortedBuilder
+// keeps in memory before it writes a sorted run to a temporary file.
+// The default budget is 64 MiB.
+func WithMemoryBudget(n int) UnsortedOption {
+	return unsortedOption(func(c *unsortedConfig) {
+		c.memory = n
+	})
+}
+
+// WithTempDir sets the directory for the temporary files of an
+// UnsortedBuilder. By default, os.TempDir() is used.
+func WithTempDir(dir string) UnsortedOption {
+	return unsortedOption(func(c *unsortedConfig) {
+		c.tmpdir = dir
+	})
+}
+
+type unsortedEntry struct {
+	key  string
+	data int32
+}
+
+// entryOverhead is the estimated memory overhead of an entry.
+const entryOverhead = 24
+
+// UnsortedBuilder builds a DFA from entries in arbitrary order.
+// The entries are sorted using an external merge sort: if the entries
+// exceed the memory budget, they are written as sorted runs to temporary
+// files, which are merged when the DFA is built.
+type UnsortedBuilder struct {
+	config  unsortedConfig
+	entries []unsortedEntry
+	size    int
+	runs    []string
+}
+
+// NewUnsortedBuilder returns a new instance of an UnsortedBuilder.
+func NewUnsortedBuilder(opts ...UnsortedOption) *UnsortedBuilder {
+	config := unsortedConfig{builder: newBuilderConfig(), memory: 64 << 20}
+	for _, opt := range opts {
+		opt.applyUnsorted(&config)
+	}
+	return &UnsortedBuilder{config: config}
+}
+
+// Add adds a (string, value) pair. The strings can be added in any order.
+// Add returns an error iff a sorted run could not be written.
+func (b *UnsortedBuilder) Add(str string, data int32) error {
+	b.entries = append(b.entries, unsortedEntry{key: str, data: data})
+	b.size += len(str) + entryOverhead
+	if b.size >= b.config.memory {
+		return b.spill()
+	}
+	return nil
+}
+
+// Build merges all entries and returns the DFA. Repeated keys are handled
+// according to the duplicate policy of the builder; the data of repeated
+// keys is passed to the policy in the order in which the keys were added.
+// Build removes all temporary files.
+func (b *UnsortedBuilder) Build() (*DFA, error) {
+	return b.BuildContext(context.Background())
+}
+
+// BuildContext works like Build, but aborts the build
+// if the given context is done.
+func (b *UnsortedBuilder) BuildContext(ctx context.Context) (*DFA, error) {
+	defer b.Close()
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		return b.entries[i].key < b.entries[j].key
+	})
+	m := &runMerger{}
+	for i, run := range b.runs {
+		file, err := os.Open(run)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not open sorted run")
+		}
+		defer file.Close()
+		if err := m.add(&fileRun{r: bufio.NewReader(file)}, i); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.add(&memoryRun{entries: b.entries}, len(b.runs)); err != nil {
+		return nil, err
+	}
+	builder := &Builder{config: b.config.builder}
+	for n := 1; m.Len() > 0; n++ {
+		if n%checkContext == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		e, err := m.next()
+		if err != nil {
+			return nil, err
+		}
+		if err := builder.Add(e.key, e.data); err != nil {
+			return nil, err
+		}
+	}
+	return builder.Build(), nil
+}
+
+// Close removes all temporary files of the builder.
+func (b *UnsortedBuilder) Close() error {
+	var err error
+	for _, run := range b.runs {
+		if e := os.Remove(run); e != nil && err == nil {
+			err = e
+		}
+	}
+	b.runs = nil
+	b.entries = nil
+	b.size = 0
+	return err
+}
+
+func (b *UnsortedBuilder) spill() error {
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		return b.entries[i].key < b.entries[j].key
+	})
+	file, err := os.CreateTemp(b.config.tmpdir, "sparsetable-run-*")
+	if err != nil {
+		return errors.Wrapf(err, "could not create sorted run")
+	}
+	b.runs = append(b.runs, file.Name())
+	w := bufio.NewWriter(file)
+	var buf [binary.MaxVarintLen64]byte
+	for _, e := range b.entries {
+		n := binary.PutUvarint(buf[:], uint64(len(e.key)))
+		w.Write(buf[:n])
+		w.WriteString(e.key)
+		n = binary.PutVarint(buf[:], int64(e.data))
+		w.Write(buf[:n])
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return errors.Wrapf(err, "could not write sorted run")
+	}
+	if err := file.Close(); err != nil {
+		return errors.Wrapf(err, "could not write sorted run")
+	}
+	b.entries = b.entries[:0]
+	b.size = 0
+	return nil
+}
+
+// run is a sorted sequence of entries. Next returns io.EOF at the end.
+type run interface {
+	next() (unsortedEntry, error)
+}
+
+type memoryRun struct {
+	entries []unsortedEntry
+}
+
+func (r *memoryRun) next() (unsortedEntry, error) {
+	if len(r.entries) == 0 {
+		return unsortedEntry{}, io.EOF
+	}
+	e := r.entries[0]
+	r.entries = r.entries[1:]
+	return e, nil
+}
+
+type fileRun struct {
+	r *bufio.Reader
+}
+
+func (r *fileRun) next() (unsortedEntry, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return unsortedEntry{}, err // io.EOF at the end of the run
+	}
+	key := make([]byte, n)
+	if _, err := io.ReadFull(r.r, key); err != nil {
+		return unsortedEntry{}, fmt.Errorf("invalid sorted run: %v", err)
+	}
+	data, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return unsortedEntry{}, fmt.Errorf("invalid sorted run: %v", err)
+	}
+	return unsortedEntry{key: string(key), data: int32(data)}, nil
+}
+
+type mergeItem struct {
+	entry unsortedEntry
+	run   run
+	index int
+}
+
+// runMerger merges sorted runs. Equal keys are returned
+// in the order of their runs.
+type runMerger []mergeItem
+
+func (m runMerger) Len() int { return len(m) }
+func (m runMerger) Less(i, j int) bool {
+	if m[i].entry.key != m[j].entry.key {
+		return m[i].entry.key < m[j].entry.key
+	}
+	return m[i].index < m[j].index
+}
+func (m runMerger) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m *runMerger) Push(x interface{}) {
+	*m = append(*m, x.(mergeItem))
+}
+func (m *runMerger) Pop() interface{} {
+	old := *m
+	x := old[len(old)-1]
+	*m = old[:len(old)-1]
+	return x
+}
+
+func (m *runMerger) add(r run, index int) error {
+	e, err := r.next()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	heap.Push(m, mergeItem{entry: e, run: r, index: index})
+	return nil
+}
+
+func (m *runMerger) next() (unsortedEntry, error) {
+	item := heap.Pop(m).(mergeItem)
+	return item.entry, m.add(item.run, item.index)
+}
@@ -0,0 +1,96 @@
+package sparsetable
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestUnsortedBuilder(t *testing.T) {
+	seed, r := makeR()
+	_, strs := makeRandomStrings(200, r)
+	for _, memory := range []int{1, 512, 1 << 20} {
+		dir := t.TempDir()
+		b := NewUnsortedBuilder(WithMemoryBudget(memory), WithTempDir(dir))
+		for i, str := range strs {
+			if err := b.Add(str, int32(i)); err != nil {
+				t.Fatalf("got error: %v", err)
+			}
+		}
+		dfa, err := b.Build()
+		if err != nil {
+			t.Fatalf("got error: %v (%d)", err, seed)
+		}
+		for i, str := range strs {
+			if data, ok := dfa.Lookup(str); !ok || data != int32(i) {
+				t.Fatalf("expected Lookup(%q) = %d, true; got %d, %t (%d)",
+					str, i, data, ok, seed)
+			}
+		}
+		if files, _ := os.ReadDir(dir); len(files) != 0 {
+			t.Fatalf("expected no temporary files; got %d", len(files))
+		}
+	}
+}
+
+func TestUnsortedBuilderDuplicates(t *testing.T) {
+	entries := []unsortedEntry{
+		{"b", 1}, {"a", 2}, {"b", 3}, {"c", 4}, {"a", 5}, {"b", 6},
+	}
+	tests := []struct {
+		name   string
+		policy DuplicatePolicy
+		want   []Entry
+	}{
+		{"error", DuplicateError, nil},
+		{"keep first", DuplicateKeepFirst, []Entry{{"a", 2}, {"b", 1}, {"c", 4}}},
+		{"keep last", DuplicateKeepLast, []Entry{{"a", 5}, {"b", 6}, {"c", 4}}},
+	}
+	for _, tc := range tests {
+		for _, memory := range []int{1, 1 << 20} {
+			t.Run(tc.name, func(t *testing.T) {
+				b := NewUnsortedBuilder(WithMemoryBudget(memory),
+					WithTempDir(t.TempDir()), WithDuplicatePolicy(tc.policy))
+				for _, e := range entries {
+					if err := b.Add(e.key, e.data); err != nil {
+						t.Fatalf("got error: %v", err)
+					}
+				}
+				dfa, err := b.Build()
+				if tc.want == nil {
+					if err == nil {
+						t.Fatalf("expected an error")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("got error: %v", err)
+				}
+				var got []Entry
+				dfa.Walk(func(word string, data int32) bool {
+					got = append(got, Entry{Word: word, Data: data})
+					return true
+				})
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Fatalf("expected %v; got %v", tc.want, got)
+				}
+			})
+		}
+	}
+}
+
+func TestUnsortedBuilderContext(t *testing.T) {
+	b := NewUnsortedBuilder(WithTempDir(t.TempDir()))
+	for i := 0; i < 2*checkContext; i++ {
+		if err := b.Add(fmt.Sprintf("%d", i), int32(i)); err != nil {
+			t.Fatalf("got error: %v", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.BuildContext(ctx); err != context.Canceled {
+		t.Fatalf("expected %v; got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,61 @@
+package sparsetable
+
+import "github.com/pkg/errors"
+
+// Validate checks the integrity of the DFA. It walks all states that are
+// reachable from the initial state and checks that the transition cells
+// of each state sit at the position of the state plus their character,
+// that the chains of transitions terminate, and that the targets of all
+// transitions point to state cells. Validate also checks that the DFA
+// is acyclic, since all DFAs built by this package are acyclic and
+// functions like Walk or Index rely on it. An empty DFA is valid.
+func (d *DFA) Validate() error {
+	if len(d.table) == 0 {
+		return nil
+	}
+	if !d.valid(d.initial, validAnyState) {
+		return errors.Errorf("invalid initial state %d", d.initial)
+	}
+	const (
+		unvisited byte = iota
+		active
+		done
+	)
+	marks := make([]byte, len(d.table))
+	var validate func(State) error
+	validate = func(s State) error {
+		switch marks[s] {
+		case active:
+			return errors.Errorf("cycle at state %d", s)
+		case done:
+			return nil
+		}
+		marks[s] = active
+		for prev, i := uint32(0), d.table[s].Next(); i > 0; prev, i = i, d.table[int(s)+int(i)].Next() {
+			pos := int(s) + int(i)
+			if i <= prev {
+				return errors.Errorf("invalid transition chain of state %d at offset %d", s, i)
+			}
+			if pos >= len(d.table) {
+				return errors.Errorf("transition %d of state %d out of range", i, s)
+			}
+			cell := d.table[pos]
+			if !cell.Transition() {
+				return errors.Errorf("invalid cell at %d of state %d: %s", pos, s, cell)
+			}
+			if uint32(cell.Char()) != i {
+				return errors.Errorf("transition cell at %d of state %d has invalid char %d", pos, s, cell.Char())
+			}
+			t := State(cell.Target())
+			if !d.valid(t, validAnyState) {
+				return errors.Errorf("transition cell at %d of state %d has invalid target %d", pos, s, t)
+			}
+			if err := validate(t); err != nil {
+				return err
+			}
+		}
+		marks[s] = done
+		return nil
+	}
+	return validate(d.initial)
+}
@@ -0,0 +1,64 @@
+package sparsetable
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, tc := range [][]string{{}, {""}, {"abc", "def"}, {"für", "yбĸ", "z│ή"}, teststrs} {
+		t.Run(fmt.Sprintf("%v", tc), func(t *testing.T) {
+			if err := NewDictionary(tc...).Validate(); err != nil {
+				t.Fatalf("expected valid DFA; got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial State
+		table   []Cell
+	}{
+		{"invalid initial", 3, []Cell{NewFinalCell(0, 0)}},
+		{"initial transition", 0, []Cell{NewTransitionCell(0, 0, 0)}},
+		{"chain out of range", 0, []Cell{NewNonFinalCell(5), NewFinalCell(0, 0)}},
+		{"chain not terminated", 0, []Cell{
+			NewNonFinalCell(1), NewTransitionCell(3, 1, 1), {}, NewFinalCell(0, 0)}},
+		{"invalid char", 0, []Cell{
+			NewNonFinalCell(1), NewTransitionCell(2, 'a', 0), NewFinalCell(0, 0)}},
+		{"state in chain", 0, []Cell{
+			NewNonFinalCell(1), NewFinalCell(0, 0)}},
+		{"target out of range", 0, []Cell{
+			NewNonFinalCell(1), NewTransitionCell(7, 1, 0)}},
+		{"target transition", 0, []Cell{
+			NewNonFinalCell(1), NewTransitionCell(1, 1, 0)}},
+		{"cycle", 0, []Cell{
+			NewNonFinalCell(1), NewTransitionCell(0, 1, 0)}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dfa := &DFA{initial: tc.initial, table: tc.table}
+			if err := dfa.Validate(); err == nil {
+				t.Fatalf("expected an error")
+			}
+		})
+	}
+}
+
+func TestReadDFAWithValidation(t *testing.T) {
+	cyclic := &DFA{table: []Cell{NewFinalCell(0, 1), NewTransitionCell(0, 1, 0)}}
+	buffer := new(bytes.Buffer)
+	if _, err := cyclic.WriteTo(buffer); err != nil {
+		t.Fatalf("could not write DFA: %v", err)
+	}
+	bs := buffer.Bytes()
+	if _, err := ReadDFA(bytes.NewReader(bs)); err != nil {
+		t.Fatalf("could not read DFA: %v", err)
+	}
+	if _, err := ReadDFA(bytes.NewReader(bs), WithValidation()); err == nil {
+		t.Fatalf("expected an error")
+	}
+}